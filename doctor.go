@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/samber/lo"
+
+	"github.com/dirk/quickhook/hooks"
+	"github.com/dirk/quickhook/repo"
+)
+
+// Returned by doctor if there are problems which weren't fixed.
+var errDoctorProblems = errors.New("doctor found problems")
+
+type doctorProblem struct {
+	message string
+	// Repairs the problem and returns a description of what it did. Nil if the problem can't be
+	// fixed automatically.
+	fix func() (string, error)
+}
+
+// Checks for things which make hooks fail in confusing ways: the environment Quickhook needs, the
+// files in .quickhook, and the shims. If fix is true then it repairs the problems it safely can.
+// Returns errDoctorProblems if any problems remain.
+func doctor(repo *repo.Repo, fix bool) error {
+	problems := checkEnvironment()
+	hookProblems, err := checkHookFiles(repo)
+	if err != nil {
+		return err
+	}
+	problems = append(problems, hookProblems...)
+	shimProblems, err := checkShimFiles(repo)
+	if err != nil {
+		return err
+	}
+	problems = append(problems, shimProblems...)
+
+	if len(problems) == 0 {
+		fmt.Println("No problems found")
+		return nil
+	}
+
+	remaining := 0
+	for _, problem := range problems {
+		if fix && problem.fix != nil {
+			fixed, err := problem.fix()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%v %v\n", color.GreenString("Fixed:"), fixed)
+			continue
+		}
+		remaining += 1
+		message := problem.message
+		if problem.fix != nil {
+			message += " (fix with --fix)"
+		}
+		fmt.Printf("%v %v\n", color.RedString("Problem:"), message)
+	}
+	if remaining > 0 {
+		return errDoctorProblems
+	}
+	return nil
+}
+
+func checkEnvironment() []doctorProblem {
+	problems := []doctorProblem{}
+	// Needed to run Quickhook at all, and by the Git shim for pre-commit hooks.
+	if _, err := exec.LookPath("git"); err != nil {
+		problems = append(problems, doctorProblem{message: "git isn't on $PATH"})
+	}
+	if err := checkTempDir(); err != nil {
+		problems = append(problems, doctorProblem{
+			message: fmt.Sprintf("Can't run executables from the temporary directory %v (set $TMPDIR to change it): %v",
+				os.TempDir(), err),
+		})
+	}
+	return problems
+}
+
+// The pre-commit hook writes a Git shim into a temporary directory and runs it, so the directory
+// needs to be writable and not mounted noexec.
+func checkTempDir() error {
+	dir, err := os.MkdirTemp("", "quickhook-doctor-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	script := path.Join(dir, "check")
+	err = os.WriteFile(script, []byte("#!/bin/sh\nexit 0\n"), 0755)
+	if err != nil {
+		return err
+	}
+	return exec.Command(script).Run()
+}
+
+func checkHookFiles(repo *repo.Repo) ([]doctorProblem, error) {
+	entries, err := os.ReadDir(path.Join(repo.Root, ".quickhook"))
+	if os.IsNotExist(err) {
+		return []doctorProblem{{message: "Missing hooks directory .quickhook"}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	problems := []doctorProblem{}
+	for _, entry := range entries {
+		hook := entry.Name()
+		if !entry.IsDir() || !(lo.Contains(hooks.GIT_HOOKS, hook) || hook == hooks.PRE_COMMIT_MUTATING_HOOK) {
+			continue
+		}
+		executables, nonExecutables, err := repo.ListHookFiles(hook)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range nonExecutables {
+			problem, err := checkHookFile(repo, name, false)
+			if err != nil {
+				return nil, err
+			}
+			problems = append(problems, problem...)
+		}
+		for _, name := range executables {
+			problem, err := checkHookFile(repo, name, true)
+			if err != nil {
+				return nil, err
+			}
+			problems = append(problems, problem...)
+		}
+	}
+	return problems, nil
+}
+
+func checkHookFile(repo *repo.Repo, name string, executable bool) ([]doctorProblem, error) {
+	fullPath := repo.Abs(name)
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	if isBinaryExecutable(data) {
+		return nil, nil
+	}
+
+	problems := []doctorProblem{}
+	hasShebang := bytes.HasPrefix(data, []byte("#!"))
+	if !executable {
+		if !hasShebang {
+			// Probably not meant to be a hook (eg. a README), but it won't be run either way.
+			return []doctorProblem{{message: fmt.Sprintf("%v isn't executable, so it won't be run", name)}}, nil
+		}
+		problems = append(problems, doctorProblem{
+			message: fmt.Sprintf("%v isn't executable", name),
+			fix: func() (string, error) {
+				info, err := os.Stat(fullPath)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("made %v executable", name), os.Chmod(fullPath, info.Mode()|0111)
+			},
+		})
+	}
+
+	if bytes.Contains(data, []byte("\r\n")) {
+		problems = append(problems, doctorProblem{
+			message: fmt.Sprintf("%v has CRLF line endings", name),
+			fix: func() (string, error) {
+				converted := bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+				info, err := os.Stat(fullPath)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("converted %v to LF line endings", name), os.WriteFile(fullPath, converted, info.Mode())
+			},
+		})
+	}
+
+	if !hasShebang {
+		problems = append(problems, doctorProblem{
+			message: fmt.Sprintf("%v doesn't start with a shebang (eg. #!/bin/sh)", name),
+		})
+	} else if interpreter := shebangInterpreter(data); interpreter != "" {
+		if _, err := exec.LookPath(interpreter); err != nil {
+			problems = append(problems, doctorProblem{
+				message: fmt.Sprintf("%v uses interpreter %v which can't be found", name, interpreter),
+			})
+		}
+	}
+	return problems, nil
+}
+
+// Returns the interpreter from the shebang line, looking through /usr/bin/env to the program it
+// runs.
+func shebangInterpreter(data []byte) string {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSuffix(string(line), "\r"), "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+	if path.Base(fields[0]) == "env" {
+		for _, field := range fields[1:] {
+			// Skip flags (eg. -S) and variable assignments.
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				return field
+			}
+		}
+	}
+	return fields[0]
+}
+
+// Returns true for ELF and Mach-O executables.
+func isBinaryExecutable(data []byte) bool {
+	magics := [][]byte{
+		[]byte("\x7fELF"),
+		{0xfe, 0xed, 0xfa, 0xce},
+		{0xfe, 0xed, 0xfa, 0xcf},
+		{0xce, 0xfa, 0xed, 0xfe},
+		{0xcf, 0xfa, 0xed, 0xfe},
+		{0xca, 0xfe, 0xba, 0xbe},
+	}
+	return lo.SomeBy(magics, func(magic []byte) bool {
+		return bytes.HasPrefix(data, magic)
+	})
+}
+
+func checkShimFiles(repo *repo.Repo) ([]doctorProblem, error) {
+	if _, err := os.Stat(path.Join(repo.Root, ".quickhook")); err != nil {
+		return nil, nil
+	}
+	report, err := collectStatus(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	// Missing shims are installed the same way as the existing ones.
+	like := &shimInfo{format: SHIM_FORMAT, quickhook: "quickhook"}
+	for _, hook := range report.Hooks {
+		if hook.ShimStatus == SHIM_INSTALLED || hook.ShimStatus == SHIM_OUTDATED {
+			like = &shimInfo{quickhook: hook.Quickhook, symlink: hook.Symlink}
+			break
+		}
+	}
+
+	problems := []doctorProblem{}
+	for _, hook := range report.Hooks {
+		hook := hook
+		switch hook.ShimStatus {
+		case SHIM_MISSING:
+			if len(hook.Executables) == 0 {
+				continue
+			}
+			problems = append(problems, doctorProblem{
+				message: fmt.Sprintf("No shim for the hooks in .quickhook/%v", hook.Hook),
+				fix: func() (string, error) {
+					return fmt.Sprintf("installed shim %v", hook.Shim), reinstallShim(repo, hook.Shim, hook.Hook, like)
+				},
+			})
+			continue
+		case SHIM_OUTDATED:
+			problems = append(problems, doctorProblem{
+				message: fmt.Sprintf("Shim %v is outdated", hook.Shim),
+				fix: func() (string, error) {
+					return fmt.Sprintf("updated shim %v", hook.Shim),
+						installShim(repo, hook.Shim, hook.Quickhook, hook.Hook)
+				},
+			})
+		case SHIM_OTHER:
+			// Symlinks to an executable which has since been removed.
+			if target, err := os.Readlink(repo.Abs(hook.Shim)); err == nil {
+				if _, err := os.Stat(repo.Abs(hook.Shim)); err != nil {
+					problems = append(problems, doctorProblem{
+						message: fmt.Sprintf("Hook %v links to %v which doesn't exist", hook.Shim, target),
+					})
+				}
+			}
+			continue
+		}
+
+		if !hook.Symlink && checkQuickhookCommand(repo.Root, hook.Quickhook) != nil {
+			problems = append(problems, doctorProblem{
+				message: fmt.Sprintf("Shim %v runs %v which can't be found (reinstall with --bin to change it)",
+					hook.Shim, hook.Quickhook),
+			})
+		}
+	}
+	return problems, nil
+}
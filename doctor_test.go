@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoctorNoProblems(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--bin="+tempDir.Quickhook)
+	require.NoError(t, err)
+
+	output, err := tempDir.ExecQuickhook("doctor")
+	assert.NoError(t, err)
+	assert.Equal(t, "No problems found\n", output)
+}
+
+func TestDoctorFix(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--bin="+tempDir.Quickhook)
+	require.NoError(t, err)
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "crlf"}, "#!/bin/sh\r\necho \"CRLF\"\r\n")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "not-executable"}, "#!/bin/sh\necho \"Not executable\"\n")
+	tempDir.RequireExec("chmod", "-x", ".quickhook/pre-commit/not-executable")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "no-shebang"}, "echo \"No shebang\"\n")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "missing-interpreter"}, "#!/usr/bin/env not-a-real-interpreter\n")
+	tempDir.MkdirAll(".quickhook", "commit-msg")
+	tempDir.WriteFile([]string{".quickhook", "commit-msg", "checks"}, "#!/bin/sh\n")
+
+	output, err := tempDir.ExecQuickhook("doctor")
+	assert.Error(t, err)
+	for _, problem := range []string{
+		"Problem: .quickhook/pre-commit/crlf has CRLF line endings (fix with --fix)\n",
+		"Problem: .quickhook/pre-commit/not-executable isn't executable (fix with --fix)\n",
+		"Problem: .quickhook/pre-commit/no-shebang doesn't start with a shebang (eg. #!/bin/sh)\n",
+		"Problem: .quickhook/pre-commit/missing-interpreter uses interpreter not-a-real-interpreter which can't be found\n",
+		"Problem: No shim for the hooks in .quickhook/commit-msg (fix with --fix)\n",
+	} {
+		assert.Contains(t, output, problem)
+	}
+
+	output, err = tempDir.ExecQuickhook("doctor", "--fix")
+	assert.Error(t, err)
+	for _, fixed := range []string{
+		"Fixed: converted .quickhook/pre-commit/crlf to LF line endings\n",
+		"Fixed: made .quickhook/pre-commit/not-executable executable\n",
+		"Fixed: installed shim .git/hooks/commit-msg\n",
+	} {
+		assert.Contains(t, output, fixed)
+	}
+	assert.Contains(t, output, "Problem: .quickhook/pre-commit/no-shebang")
+
+	data, err := os.ReadFile(path.Join(tempDir.Root, ".quickhook", "pre-commit", "crlf"))
+	assert.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\necho \"CRLF\"\n", string(data))
+	info, err := readShim(path.Join(tempDir.Root, ".git", "hooks", "commit-msg"))
+	assert.NoError(t, err)
+	assert.Equal(t, tempDir.Quickhook, info.quickhook)
+}
+
+func TestDoctorShimWithMissingQuickhook(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.MkdirAll(".git", "hooks")
+	tempDir.WriteFile([]string{".git", "hooks", "pre-commit"}, "#!/bin/sh\nmissing-quickhook hook pre-commit\n")
+
+	output, err := tempDir.ExecQuickhook("doctor", "--fix")
+	assert.Error(t, err)
+	assert.Equal(t,
+		"Fixed: updated shim .git/hooks/pre-commit\n"+
+			"Problem: Shim .git/hooks/pre-commit runs missing-quickhook which can't be found (reinstall with --bin to change it)\n",
+		output)
+}
+
+func TestShebangInterpreter(t *testing.T) {
+	assert.Equal(t, "/bin/sh", shebangInterpreter([]byte("#!/bin/sh\necho")))
+	assert.Equal(t, "ruby", shebangInterpreter([]byte("#!/usr/bin/env ruby\n")))
+	assert.Equal(t, "node", shebangInterpreter([]byte("#!/usr/bin/env -S node --no-warnings\n")))
+	assert.Equal(t, "/bin/bash", shebangInterpreter([]byte("#! /bin/bash -e\r\n")))
+}
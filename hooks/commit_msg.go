@@ -1,8 +1,6 @@
 package hooks
 
 import (
-	"os"
-
 	"github.com/dirk/quickhook/repo"
 )
 
@@ -24,7 +22,7 @@ func (hook *CommitMsg) Run(messageFile string) error {
 		}
 		result.printStderr()
 		result.printStdout()
-		os.Exit(FAILED_EXIT_CODE)
+		return ErrFailed
 	}
 	return nil
 }
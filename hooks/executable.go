@@ -2,33 +2,253 @@ package hooks
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/fatih/color"
 
+	"github.com/dirk/quickhook/internal"
+	"github.com/dirk/quickhook/repo"
 	"github.com/dirk/quickhook/tracing"
 )
 
+// Returned by hooks when one or more of their executables failed. The output of the failed
+// executables will have already been printed.
+var ErrFailed = errors.New("hook failed")
+
+var errInterrupted = errors.New("interrupted")
+
+// Default timeout for executables which don't set their own with a quickhook-timeout directive.
+// Zero means they can run forever.
+var DefaultTimeout time.Duration
+
+// How long to wait for an executable's output to be closed after killing it (eg. if it started a
+// background process in another process group which still has it open).
+const KILL_WAIT_DELAY = time.Second
+
 func runExecutable(root, executable string, env []string, stdin string, arg ...string) hookResult {
+	return runExecutableContext(context.Background(), root, executable, env, stdin, arg...)
+}
+
+// Runs the executable, killing its process group if the context is cancelled or its timeout
+// passes.
+func runExecutableContext(
+	parent context.Context, root, executable string, env []string, stdin string, arg ...string,
+) hookResult {
+	ctx, cancel := newExecutableContext(parent, root, executable)
+	defer cancel()
+	return ctx.run(root, executable, env, stdin, arg...)
+}
+
+// The context for running an executable (possibly several times, eg. for batches of files): the
+// parent context plus the executable's timeout, which covers all of the runs.
+type executableContext struct {
+	context.Context
+	parent     context.Context
+	timeout    time.Duration
+	directives internal.Directives
+}
+
+func newExecutableContext(parent context.Context, root, executable string) (*executableContext, context.CancelFunc) {
+	directives, err := readExecutableDirectives(root, executable)
+	if err != nil {
+		directives = internal.Directives{}
+	}
+	ctx := &executableContext{
+		Context:    parent,
+		parent:     parent,
+		timeout:    executableTimeout(executable, directives),
+		directives: directives,
+	}
+	cancel := func() {}
+	if ctx.timeout > 0 {
+		ctx.Context, cancel = context.WithTimeout(parent, ctx.timeout)
+	}
+	return ctx, cancel
+}
+
+// Runs the executable. Executables are only given their own process group when they might be
+// killed (by a timeout or --fail-fast).
+func (ctx *executableContext) run(root, executable string, env []string, stdin string, arg ...string) hookResult {
 	dir, command := path.Split(executable)
 	span := tracing.NewSpan(fmt.Sprintf("hook %s %s", path.Base(dir), command))
 	defer span.End()
-	cmd := exec.Command(path.Join(root, executable), arg...)
+
+	timeout, directives := ctx.timeout, ctx.directives
+	cmd := exec.CommandContext(ctx, path.Join(root, executable), arg...)
+	cmd.Dir = root
 	cmd.Env = append(os.Environ(), env...)
+	if directives.Get("jobserver") == "true" {
+		// Let it share the jobserver so that it doesn't start more jobs than Quickhook is allowed.
+		files, jobsEnv := jobs().inherit()
+		cmd.ExtraFiles = files
+		cmd.Env = append(append(os.Environ(), jobsEnv...), env...)
+	}
 	cmd.Stdin = strings.NewReader(stdin)
-	var stderr bytes.Buffer
+	killable := timeout > 0 || FailFast
+	if killable {
+		// Run it in its own process group so that everything it starts can be killed along with
+		// it. Otherwise leave it in the terminal's foreground group so it can still prompt on
+		// /dev/tty.
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		cmd.Cancel = func() error {
+			return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+		cmd.WaitDelay = KILL_WAIT_DELAY
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
-	stdout, err := cmd.Output()
-	return hookResult{
+	start := time.Now()
+	err := errInterrupted
+	// Don't start any more executables once Quickhook has been interrupted.
+	if Interrupted() == 0 {
+		err = cmd.Start()
+	}
+	if err == nil {
+		untrack := trackProcess(cmd.Process.Pid, killable)
+		err = cmd.Wait()
+		untrack()
+	}
+	result := hookResult{
 		executable: executable,
-		stdout:     string(stdout),
+		stdout:     stdout.String(),
 		stderr:     stderr.String(),
 		err:        err,
+		duration:   time.Since(start),
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.timedOut = timeout
+	} else if ctx.parent.Err() != nil {
+		result.cancelled = true
+	} else {
+		result.interrupted = Interrupted() != 0 || killedBySignal(err)
+	}
+	return result
+}
+
+func killedBySignal(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled()
+}
+
+// Returns the timeout from the executable's quickhook-timeout directive (eg. "30s" or "2m"), or
+// DefaultTimeout if it doesn't have one.
+func executableTimeout(executable string, directives internal.Directives) time.Duration {
+	value := directives.Get("timeout")
+	if value == "" {
+		return DefaultTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Invalid timeout in %v: %v\n", executable, value)
+		return DefaultTimeout
+	}
+	return timeout
+}
+
+// Stops the batches early once an executable has been cancelled or timed out.
+var errBatchesCancelled = errors.New("batches cancelled")
+
+// Runs the executable once for each batch of files, passing the batch on stdin. Its timeout applies
+// to all of the executions together. The output of all the executions is combined and the first
+// error (if any) is kept. Returns an error if the batches
+// couldn't be listed.
+func runExecutableBatches(
+	ctx context.Context, root, executable string, env []string, batches fileBatches, arg ...string,
+) (hookResult, error) {
+	executableCtx, cancel := newExecutableContext(ctx, root, executable)
+	defer cancel()
+	combined := hookResult{executable: executable}
+	err := batches(func(batch []string) error {
+		result := executableCtx.run(root, executable, env, strings.Join(batch, "\n"), arg...)
+		combined.stdout = joinOutput(combined.stdout, result.stdout)
+		combined.stderr = joinOutput(combined.stderr, result.stderr)
+		combined.duration += result.duration
+		if combined.err == nil {
+			combined.err = result.err
+			combined.timedOut = result.timedOut
+			combined.cancelled = result.cancelled
+		}
+		combined.interrupted = combined.interrupted || result.interrupted
+		if result.cancelled || result.timedOut > 0 || result.interrupted {
+			return errBatchesCancelled
+		}
+		return nil
+	})
+	if errors.Is(err, errBatchesCancelled) {
+		err = nil
+	}
+	return combined, err
+}
+
+// Appends output from another execution, making sure it starts on a new line.
+func joinOutput(output, more string) string {
+	if output != "" && !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	return output + more
+}
+
+// Runs the hook's executables in parallel and prints their output once they've all finished.
+// Returns ErrFailed if any of them failed.
+func runParallel(
+	repo *repo.Repo, hook string, executables []string, env []string, stdin string, arg ...string,
+) error {
+	history := loadHistory(repo, hook)
+	results, firstFailed := mapParallel(history, executables, func(ctx context.Context, executable string) hookResult {
+		return runExecutableContext(ctx, repo.Root, executable, env, stdin, arg...)
+	})
+	if checkParallelResults(results, firstFailed) {
+		return ErrFailed
+	}
+	return nil
+}
+
+// Writes the data to a new temporary file and returns its path, along with a function to remove
+// it (which is also registered as a cleanup, so it's removed if Quickhook is interrupted).
+func writeTempFile(pattern string, data []byte) (string, func(), error) {
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+	remove := internal.AddCleanup(func() { os.Remove(file.Name()) })
+	_, err = file.Write(data)
+	if err != nil {
+		remove()
+		return "", nil, err
+	}
+	return file.Name(), remove, nil
+}
+
+// Prints the output of the executable: just stderr if it succeeded, or stderr and stdout if it
+// failed. Returns true if the executable errored, false if it did not.
+func checkResult(result hookResult) bool {
+	if result.err == nil {
+		// Print any stderr even if the hook executable succeeded.
+		result.printStderr()
+		return false
+	}
+	if result.timedOut > 0 {
+		fmt.Printf("%s: %s\n",
+			color.RedString("%s", path.Base(result.executable)),
+			color.RedString("timed out after %v", result.timedOut))
 	}
+	result.printStderr()
+	result.printStdout()
+	return true
 }
 
 type hookResult struct {
@@ -36,6 +256,15 @@ type hookResult struct {
 	stdout     string
 	stderr     string
 	err        error
+	// The timeout if the executable was killed because it took too long.
+	timedOut time.Duration
+	// Whether the executable was killed (or never started) because of fail-fast.
+	cancelled bool
+	// Whether the executable was interrupted along with Quickhook (or never started because of
+	// that), or was killed by a signal.
+	interrupted bool
+	// How long it took to run.
+	duration time.Duration
 }
 
 func (result *hookResult) printStdout() {
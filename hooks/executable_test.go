@@ -0,0 +1,114 @@
+package hooks
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHookTimeoutDirective(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile(
+		[]string{".quickhook", "pre-commit", "slow"},
+		"#!/bin/sh \n# quickhook-timeout: 200ms\n echo \"partial\" \n sleep 60")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "fast"}, "#!/bin/sh \n exit 0")
+
+	start := time.Now()
+	output, err := tempDir.ExecQuickhook("hook", "pre-commit")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Equal(t, "slow: timed out after 200ms\nslow: partial\n", output)
+}
+
+func TestHookTimeoutCoversAllBatches(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	// Each batch finishes within the timeout, but all of them together don't.
+	tempDir.WriteFile(
+		[]string{".quickhook", "pre-commit", "slow"},
+		"#!/bin/sh \n# quickhook-timeout: 1s\n cat >> batches.txt \n echo >> batches.txt \n sleep 0.6")
+
+	output, err := tempDir.ExecQuickhook("hook", "pre-commit", "--files=a,b,c", "--batch-size=1")
+	assert.Error(t, err)
+	assert.Equal(t, "slow: timed out after 1s\n", output)
+	data, err := os.ReadFile(path.Join(tempDir.Root, "batches.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, strings.Fields(string(data)))
+}
+
+func TestHookTimeoutKillsProcessGroup(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile(
+		[]string{".quickhook", "pre-commit", "slow"},
+		"#!/bin/sh \n sleep 60 & \n echo $! > child.pid \n wait")
+
+	output, err := tempDir.ExecQuickhook("--timeout=200ms", "hook", "pre-commit")
+	assert.Error(t, err)
+	assert.Equal(t, "slow: timed out after 200ms\n", output)
+
+	data, err := os.ReadFile(path.Join(tempDir.Root, "child.pid"))
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+	// The child may take a moment to be reaped.
+	assert.Eventually(t, func() bool {
+		return syscall.Kill(pid, 0) != nil
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestHookKeepsProcessGroupUnlessKillable(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "pgid"}, "#!/bin/sh \n ps -o pgid= -p $$ > pgid")
+	readPgid := func() int {
+		data, err := os.ReadFile(path.Join(tempDir.Root, "pgid"))
+		require.NoError(t, err)
+		pgid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		require.NoError(t, err)
+		return pgid
+	}
+
+	// Without a timeout it stays in the foreground group so that it can still use the terminal.
+	_, err := tempDir.ExecQuickhook("hook", "pre-commit")
+	require.NoError(t, err)
+	assert.Equal(t, syscall.Getpgrp(), readPgid())
+
+	_, err = tempDir.ExecQuickhook("--timeout=1m", "hook", "pre-commit")
+	require.NoError(t, err)
+	assert.NotEqual(t, syscall.Getpgrp(), readPgid())
+}
+
+func TestFailFast(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "fails"}, "#!/bin/sh \n echo \"broken\" \n exit 1")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "slow"}, "#!/bin/sh \n echo \"partial\" \n sleep 60")
+
+	start := time.Now()
+	output, err := tempDir.ExecQuickhook("--fail-fast", "hook", "pre-commit")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Equal(t, "fails: broken\nCancelled slow since fails failed\n", output)
+}
+
+func TestWithoutFailFast(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "fails"}, "#!/bin/sh \n echo \"broken\" \n exit 1")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "slow"}, "#!/bin/sh \n sleep 0.5 \n echo \"finished\" \n exit 1")
+
+	output, err := tempDir.ExecQuickhook("hook", "pre-commit")
+	assert.Error(t, err)
+	assert.Contains(t, output, "fails: broken\n")
+	assert.Contains(t, output, "slow: finished\n")
+	assert.NotContains(t, output, "Cancelled")
+}
@@ -0,0 +1,102 @@
+package hooks
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/dirk/quickhook/repo"
+)
+
+// How long each of a hook's executables took the last time it ran, so that the slowest can be
+// started first when they can't all run at once. Kept in .git/quickhook/history/<hook>.json.
+type hookHistory struct {
+	file string
+	// Keyed by the executable's name.
+	durations map[string]time.Duration
+}
+
+// Loads the history for the hook. It's only used for scheduling, so if it can't be loaded then
+// it's empty rather than an error.
+func loadHistory(repo *repo.Repo, hook string) *hookHistory {
+	history := &hookHistory{durations: map[string]time.Duration{}}
+	quickhookDir, err := repo.QuickhookDir()
+	if err != nil {
+		return history
+	}
+	history.file = path.Join(quickhookDir, "history", hook+".json")
+	data, err := os.ReadFile(history.file)
+	if err == nil && json.Unmarshal(data, &history.durations) != nil {
+		history.durations = map[string]time.Duration{}
+	}
+	return history
+}
+
+// Returns the indexes of the executables in the order they should be started: the slowest first,
+// with ones which haven't run before ahead of them all since they could be slow too.
+func (history *hookHistory) order(executables []string) []int {
+	indexes := make([]int, len(executables))
+	for index := range executables {
+		indexes[index] = index
+	}
+	if history == nil {
+		return indexes
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		durationA, knownA := history.durations[path.Base(executables[indexes[a]])]
+		durationB, knownB := history.durations[path.Base(executables[indexes[b]])]
+		if knownA != knownB {
+			return !knownA
+		}
+		return durationA > durationB
+	})
+	return indexes
+}
+
+// Records how long each executable took, forgetting any which weren't run. Executables which were
+// cancelled, interrupted, or killed by a signal keep their previous duration since they didn't get
+// to finish.
+func (history *hookHistory) record(results []hookResult) {
+	if history == nil || history.file == "" {
+		return
+	}
+	durations := map[string]time.Duration{}
+	for _, result := range results {
+		name := path.Base(result.executable)
+		if result.cancelled || result.interrupted {
+			if previous, ok := history.durations[name]; ok {
+				durations[name] = previous
+			}
+			continue
+		}
+		durations[name] = result.duration
+	}
+	history.durations = durations
+
+	data, err := json.MarshalIndent(durations, "", "  ")
+	if err != nil {
+		return
+	}
+	// Errors are ignored since the history is only an optimization (and the Git directory may not
+	// be writable, eg. on a server).
+	dir := path.Dir(history.file)
+	if os.MkdirAll(dir, 0755) != nil {
+		return
+	}
+	// Write to a temporary file and rename it so that hooks running at the same time can't see a
+	// partially written file.
+	file, err := os.CreateTemp(dir, path.Base(history.file)+".*")
+	if err != nil {
+		return
+	}
+	_, err = file.Write(data)
+	file.Close()
+	if err == nil {
+		err = os.Rename(file.Name(), history.file)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+}
@@ -0,0 +1,85 @@
+package hooks
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryOrder(t *testing.T) {
+	history := &hookHistory{durations: map[string]time.Duration{
+		"fast": time.Millisecond,
+		"slow": time.Second,
+	}}
+	executables := []string{".quickhook/pre-commit/fast", ".quickhook/pre-commit/new", ".quickhook/pre-commit/slow"}
+	assert.Equal(t, []int{1, 2, 0}, history.order(executables))
+
+	var empty *hookHistory
+	assert.Equal(t, []int{0, 1, 2}, empty.order(executables))
+}
+
+func TestHistorySchedulesSlowestFirst(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "fast"}, "#!/bin/sh \n echo fast >> started.txt")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "slow"}, "#!/bin/sh \n echo slow >> started.txt \n sleep 0.3")
+
+	_, err := tempDir.ExecQuickhook("--jobs=1", "hook", "pre-commit")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path.Join(tempDir.Root, ".git", "quickhook", "history", "pre-commit.json"))
+	require.NoError(t, err)
+	durations := map[string]time.Duration{}
+	require.NoError(t, json.Unmarshal(data, &durations))
+	assert.Len(t, durations, 2)
+	assert.Greater(t, durations["slow"], durations["fast"])
+
+	// Only check the second run since the first had no history to go on.
+	require.NoError(t, os.Remove(path.Join(tempDir.Root, "started.txt")))
+	_, err = tempDir.ExecQuickhook("--jobs=1", "hook", "pre-commit")
+	require.NoError(t, err)
+	data, err = os.ReadFile(path.Join(tempDir.Root, "started.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"slow", "fast"}, strings.Fields(string(data)))
+}
+
+func TestHistoryKeepsDurationOfInterruptedExecutables(t *testing.T) {
+	history := &hookHistory{
+		file: path.Join(t.TempDir(), "pre-commit.json"),
+		durations: map[string]time.Duration{
+			"interrupted": time.Second,
+			"finished":    time.Second,
+		},
+	}
+	history.record([]hookResult{
+		{executable: ".quickhook/pre-commit/interrupted", err: errInterrupted, interrupted: true},
+		{executable: ".quickhook/pre-commit/finished", duration: time.Millisecond},
+	})
+	assert.Equal(t, map[string]time.Duration{
+		"interrupted": time.Second,
+		"finished":    time.Millisecond,
+	}, history.durations)
+}
+
+func TestHistoryKeepsDurationOfExecutablesKilledBySignal(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "killed"}, "#!/bin/sh \n kill -KILL $$")
+	tempDir.MkdirAll(".git", "quickhook", "history")
+	tempDir.WriteFile([]string{".git", "quickhook", "history", "pre-commit.json"}, `{"killed": 60000000000}`)
+
+	_, err := tempDir.ExecQuickhook("hook", "pre-commit")
+	assert.Error(t, err)
+
+	data, err := os.ReadFile(path.Join(tempDir.Root, ".git", "quickhook", "history", "pre-commit.json"))
+	require.NoError(t, err)
+	durations := map[string]time.Duration{}
+	require.NoError(t, json.Unmarshal(data, &durations))
+	assert.Equal(t, map[string]time.Duration{"killed": time.Minute}, durations)
+}
@@ -0,0 +1,245 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Maximum number of executables to run at once. Zero means DEFAULT_EXTRA_JOBS more than the number
+// of CPUs.
+var Jobs int
+
+// Hooks often spend much of their time waiting on the disk or network, so run a few more than there
+// are CPUs (like Ninja does). This also means a machine with one CPU still runs hooks in parallel.
+const DEFAULT_EXTRA_JOBS = 2
+
+// Limits how many executables run at once, sharing the limit with GNU make via its jobserver
+// protocol: https://www.gnu.org/software/make/manual/html_node/Job-Slots.html
+//
+// If Quickhook was run by make with a jobserver then it's a client of that one. Otherwise it's the
+// server for a jobserver of its own, so that hooks which run make share Quickhook's limit. Only
+// hooks with a "quickhook-jobserver: true" directive are given the jobserver. Like
+// make, Quickhook has one implicit token and needs to read another from the jobserver for each
+// additional executable it runs.
+type jobserver struct {
+	// Limits executables to Jobs regardless of how many tokens the jobserver has.
+	local chan struct{}
+	// Holds Quickhook's implicit token when it's free.
+	implicit chan struct{}
+	// Set if the jobserver is a named pipe (GNU make 4.4+), in which case read and write are the
+	// same file.
+	fifo  string
+	read  *os.File
+	write *os.File
+	// MAKEFLAGS to pass to executables; empty to leave it unchanged.
+	makeflags string
+}
+
+var jobs = sync.OnceValue(newJobserver)
+
+func newJobserver() *jobserver {
+	limit := Jobs
+	if limit <= 0 {
+		limit = runtime.NumCPU() + DEFAULT_EXTRA_JOBS
+	}
+	server := &jobserver{
+		local:    make(chan struct{}, limit),
+		implicit: make(chan struct{}, 1),
+	}
+	server.implicit <- struct{}{}
+
+	makeflags := os.Getenv("MAKEFLAGS")
+	auth := parseJobserverAuth(makeflags)
+	if auth != "" {
+		err := server.connect(auth)
+		if err == nil {
+			if server.fifo == "" {
+				// The jobserver's file descriptors are always passed on as 3 and 4.
+				server.makeflags = replaceJobserverAuth(makeflags, "3,4")
+			}
+			return server
+		}
+		fmt.Fprintf(os.Stderr, "Warning: Can't use jobserver from MAKEFLAGS: %v\n", err)
+	}
+
+	err := server.serve(limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Can't start jobserver: %v\n", err)
+		return server
+	}
+	server.makeflags = strings.TrimSpace(
+		fmt.Sprintf("%s -j%d --jobserver-auth=3,4", removeJobsFlags(makeflags), limit))
+	return server
+}
+
+// Connects to the jobserver described by the value of a --jobserver-auth flag: either "R,W" file
+// descriptors or "fifo:PATH".
+func (server *jobserver) connect(auth string) error {
+	if fifo, ok := strings.CutPrefix(auth, "fifo:"); ok {
+		file, err := os.OpenFile(fifo, os.O_RDWR, 0)
+		if err != nil {
+			return err
+		}
+		server.fifo = fifo
+		server.read = file
+		server.write = file
+		return nil
+	}
+	read, write, ok := strings.Cut(auth, ",")
+	if !ok {
+		return fmt.Errorf("invalid --jobserver-auth: %v", auth)
+	}
+	readFd, err := jobserverFd(read)
+	if err != nil {
+		return err
+	}
+	writeFd, err := jobserverFd(write)
+	if err != nil {
+		return err
+	}
+	server.read = os.NewFile(uintptr(readFd), "jobserver-read")
+	server.write = os.NewFile(uintptr(writeFd), "jobserver-write")
+	return nil
+}
+
+// Parses and checks a jobserver file descriptor. make closes them for commands which it doesn't
+// think are recursive (ie. that aren't prefixed with "+" or don't mention $(MAKE)).
+func jobserverFd(value string) (int, error) {
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid jobserver file descriptor: %v", value)
+	}
+	var stat syscall.Stat_t
+	if syscall.Fstat(fd, &stat) != nil || stat.Mode&syscall.S_IFMT != syscall.S_IFIFO {
+		return 0, fmt.Errorf("file descriptor %d isn't open, add \"+\" to the make rule", fd)
+	}
+	return fd, nil
+}
+
+// Creates a pipe holding a token for each job other than Quickhook's implicit one.
+func (server *jobserver) serve(limit int) error {
+	var fds [2]int
+	err := syscall.Pipe(fds[:])
+	if err != nil {
+		return err
+	}
+	syscall.CloseOnExec(fds[0])
+	syscall.CloseOnExec(fds[1])
+	server.read = os.NewFile(uintptr(fds[0]), "jobserver-read")
+	server.write = os.NewFile(uintptr(fds[1]), "jobserver-write")
+	_, err = server.write.Write([]byte(strings.Repeat("+", limit-1)))
+	return err
+}
+
+// Blocks until there's a free job, returning a function to release it. If the context is
+// cancelled first then it gives up without a job and the function does nothing.
+func (server *jobserver) acquire(ctx context.Context) func() {
+	if ctx.Err() != nil {
+		return func() {}
+	}
+	select {
+	case server.local <- struct{}{}:
+	case <-ctx.Done():
+		return func() {}
+	}
+	select {
+	case <-server.implicit:
+		return func() {
+			server.implicit <- struct{}{}
+			<-server.local
+		}
+	default:
+	}
+	if server.read == nil {
+		// Without a jobserver the local limit is all there is.
+		return func() { <-server.local }
+	}
+	// Reading from the jobserver can't be cancelled, so it's done in the background.
+	tokens := make(chan []byte, 1)
+	go func() {
+		token := make([]byte, 1)
+		_, err := server.read.Read(token)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Can't read from jobserver: %v\n", err)
+			token = nil
+		}
+		tokens <- token
+	}()
+	select {
+	case token := <-tokens:
+		if token == nil {
+			return func() { <-server.local }
+		}
+		return func() {
+			// make expects every token to be returned as it was read.
+			server.write.Write(token)
+			<-server.local
+		}
+	case <-ctx.Done():
+		<-server.local
+		go func() {
+			// Give back the token if it turns up after all.
+			if token := <-tokens; token != nil {
+				server.write.Write(token)
+			}
+		}()
+		return func() {}
+	}
+}
+
+// Returns the files and environment to give an executable so that it can use the jobserver.
+func (server *jobserver) inherit() ([]*os.File, []string) {
+	env := []string{}
+	if server.makeflags != "" {
+		env = append(env, "MAKEFLAGS="+server.makeflags)
+	}
+	if server.read == nil || server.fifo != "" {
+		// A named pipe is found through MAKEFLAGS alone.
+		return nil, env
+	}
+	return []*os.File{server.read, server.write}, env
+}
+
+// Returns the value of the last --jobserver-auth (or the older --jobserver-fds) flag in MAKEFLAGS,
+// or an empty string if there isn't one.
+func parseJobserverAuth(makeflags string) string {
+	auth := ""
+	for _, field := range strings.Fields(makeflags) {
+		for _, prefix := range []string{"--jobserver-auth=", "--jobserver-fds="} {
+			if value, ok := strings.CutPrefix(field, prefix); ok {
+				auth = value
+			}
+		}
+	}
+	return auth
+}
+
+func replaceJobserverAuth(makeflags, auth string) string {
+	fields := strings.Fields(makeflags)
+	for index, field := range fields {
+		if strings.HasPrefix(field, "--jobserver-auth=") || strings.HasPrefix(field, "--jobserver-fds=") {
+			fields[index] = "--jobserver-auth=" + auth
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// Removes any -j and jobserver flags so that Quickhook can add its own.
+func removeJobsFlags(makeflags string) string {
+	fields := []string{}
+	for _, field := range strings.Fields(makeflags) {
+		if strings.HasPrefix(field, "-j") ||
+			strings.HasPrefix(field, "--jobserver-auth=") ||
+			strings.HasPrefix(field, "--jobserver-fds=") {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return strings.Join(fields, " ")
+}
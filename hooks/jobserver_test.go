@@ -0,0 +1,120 @@
+package hooks
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dirk/quickhook/internal/test"
+)
+
+func TestParseJobserverAuth(t *testing.T) {
+	assert.Equal(t, "", parseJobserverAuth(""))
+	assert.Equal(t, "", parseJobserverAuth("s -j4"))
+	assert.Equal(t, "3,4", parseJobserverAuth(" -j4 --jobserver-auth=3,4"))
+	assert.Equal(t, "5,6", parseJobserverAuth("-j --jobserver-fds=5,6"))
+	assert.Equal(t, "fifo:/tmp/GMfifo1", parseJobserverAuth("-j4 --jobserver-auth=fifo:/tmp/GMfifo1"))
+}
+
+func TestReplaceJobserverAuth(t *testing.T) {
+	assert.Equal(t, "s -j4 --jobserver-auth=3,4", replaceJobserverAuth("s -j4 --jobserver-auth=7,8", "3,4"))
+	assert.Equal(t, "s", removeJobsFlags("s -j4 --jobserver-auth=7,8"))
+}
+
+func TestJobsLimitsParallelHooks(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	// Each hook fails if another is running at the same time.
+	for _, name := range []string{"first", "second", "third"} {
+		tempDir.WriteFile(
+			[]string{".quickhook", "pre-commit", name},
+			"#!/bin/sh \n mkdir running || exit 1 \n sleep 0.2 \n rmdir running")
+	}
+
+	output, err := tempDir.ExecQuickhook("--jobs=1", "hook", "pre-commit")
+	assert.NoError(t, err)
+	assert.Equal(t, "", output)
+}
+
+func TestDefaultJobsRunsHooksInParallel(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	// Each hook waits for the other to start, so they only pass if they're run at the same time
+	// (even on a machine with one CPU).
+	for name, other := range map[string]string{"first": "second", "second": "first"} {
+		tempDir.WriteFile(
+			[]string{".quickhook", "pre-commit", name},
+			fmt.Sprintf("#!/bin/sh \n touch %s.started \n for i in $(seq 50); do \n [ -f %s.started ] && exit 0 \n sleep 0.1 \n done \n exit 1", name, other))
+	}
+
+	output, err := tempDir.ExecQuickhook("hook", "pre-commit")
+	assert.NoError(t, err)
+	assert.Equal(t, "", output)
+}
+
+// Writes a hook which runs make on four targets, recording how many are running at once.
+func writeMakeHook(t *testing.T, tempDir test.TempDir, directives string) {
+	if _, err := exec.LookPath("make"); err != nil {
+		t.Skip("make isn't installed")
+	}
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile([]string{"jobs.mk"}, strings.Join([]string{
+		"a b c d:",
+		"\t@touch running.$@; ls running.* 2>/dev/null | wc -l >> counts; sleep 0.5; rm running.$@",
+		"",
+	}, "\n"))
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "make"}, "#!/bin/sh \n"+directives+"make -s -f jobs.mk a b c d")
+}
+
+func maxRunning(t *testing.T, tempDir test.TempDir) int {
+	data, err := os.ReadFile(path.Join(tempDir.Root, "counts"))
+	require.NoError(t, err)
+	highest := 0
+	for _, line := range strings.Fields(string(data)) {
+		count, err := strconv.Atoi(line)
+		require.NoError(t, err)
+		highest = max(highest, count)
+	}
+	return highest
+}
+
+func TestJobserverServer(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	writeMakeHook(t, tempDir, "# quickhook-jobserver: true\n")
+
+	output, err := tempDir.ExecQuickhook("--jobs=2", "hook", "pre-commit")
+	assert.NoError(t, err)
+	assert.Equal(t, "", output)
+	assert.Equal(t, 2, maxRunning(t, tempDir))
+}
+
+func TestJobserverIsOptIn(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	writeMakeHook(t, tempDir, "")
+
+	output, err := tempDir.ExecQuickhook("--jobs=2", "hook", "pre-commit")
+	assert.NoError(t, err)
+	assert.Equal(t, "", output)
+	// Without the directive make runs its targets one at a time like it normally would.
+	assert.Equal(t, 1, maxRunning(t, tempDir))
+}
+
+func TestJobserverClient(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	writeMakeHook(t, tempDir, "# quickhook-jobserver: true\n")
+	tempDir.WriteFile([]string{"outer.mk"}, "all:\n\t+@$(QUICKHOOK) hook pre-commit\n")
+
+	cmd := tempDir.NewCommand("make", "-s", "-j3", "-f", "outer.mk", "QUICKHOOK="+tempDir.Quickhook)
+	cmd.Env = append(os.Environ(), "MAKEFLAGS=")
+	output, err := cmd.CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(output))
+	assert.Equal(t, 3, maxRunning(t, tempDir))
+}
@@ -0,0 +1,88 @@
+package hooks
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dirk/quickhook/repo"
+)
+
+// Number of logs to keep around from hooks run in the background.
+const MAX_LOGS = 20
+
+func logsDir(repo *repo.Repo) (string, error) {
+	quickhookDir, err := repo.QuickhookDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(quickhookDir, "logs"), nil
+}
+
+// Creates a new log file for a run of the hook, and removes the oldest logs if there are more
+// than MAX_LOGS.
+func newLogFile(repo *repo.Repo, hook string) (*os.File, error) {
+	dir, err := logsDir(repo)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	// Timestamp first so that the logs sort chronologically.
+	name := fmt.Sprintf("%s-%s.log", time.Now().UTC().Format("20060102T150405.000000000"), hook)
+	file, err := os.Create(path.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := listLogs(dir)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if len(logs) > MAX_LOGS {
+		for _, log := range logs[:len(logs)-MAX_LOGS] {
+			os.Remove(log)
+		}
+	}
+	return file, nil
+}
+
+// Returns the paths of up to count of the most recent logs, oldest first.
+func RecentLogs(repo *repo.Repo, count int) ([]string, error) {
+	dir, err := logsDir(repo)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := listLogs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 && len(logs) > count {
+		logs = logs[len(logs)-count:]
+	}
+	return logs, nil
+}
+
+func listLogs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	logs := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".log") {
+			logs = append(logs, path.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(logs)
+	return logs, nil
+}
@@ -0,0 +1,89 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fatih/color"
+)
+
+// Cancel the other executables running in parallel as soon as one of them fails.
+var FailFast bool
+
+// Runs the function for each executable in parallel, up to Jobs at a time and slowest first
+// according to the history. The context passed to it is cancelled once any of them fails if
+// FailFast is set. Returns the results in the same order as the executables, along with the index
+// of the first one to fail (or -1 if none did).
+func mapParallel(
+	history *hookHistory, executables []string, run func(ctx context.Context, executable string) hookResult,
+) ([]hookResult, int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var firstFailed atomic.Int32
+	firstFailed.Store(-1)
+	results := mapBounded(ctx, history, executables, func(executable string, index int) hookResult {
+		result := run(ctx, executable)
+		if result.err != nil && !result.cancelled && firstFailed.CompareAndSwap(-1, int32(index)) && FailFast {
+			cancel()
+		}
+		return result
+	})
+	return results, int(firstFailed.Load())
+}
+
+// Like lop.Map, but waits for a free job (see Jobs) before running the function for each
+// executable. They're started in the order given by the history (if any), which is then updated
+// with how long each took. Once the context is cancelled the function is still called for the
+// remaining executables (so that they're reported as cancelled) but without waiting for a job.
+func mapBounded(
+	ctx context.Context, history *hookHistory, executables []string, run func(executable string, index int) hookResult,
+) []hookResult {
+	results := make([]hookResult, len(executables))
+	var wait sync.WaitGroup
+	wait.Add(len(executables))
+	for _, index := range history.order(executables) {
+		release := jobs().acquire(ctx)
+		go func(executable string, index int) {
+			defer wait.Done()
+			defer release()
+			results[index] = run(executable, index)
+		}(executables[index], index)
+	}
+	wait.Wait()
+	history.record(results)
+	return results
+}
+
+// Prints the results from mapParallel. If executables were cancelled because of FailFast then only
+// the output of the one which failed first is printed, followed by which were cancelled. Returns
+// true if any failed.
+func checkParallelResults(results []hookResult, firstFailed int) bool {
+	cancelled := []string{}
+	for _, result := range results {
+		if result.cancelled {
+			cancelled = append(cancelled, path.Base(result.executable))
+		}
+	}
+	if len(cancelled) == 0 {
+		errored := false
+		for _, result := range results {
+			errored = checkResult(result) || errored
+		}
+		return errored
+	}
+	for index, result := range results {
+		// Other executables may also have failed before being cancelled, but only show the first.
+		if index != firstFailed && result.err == nil {
+			checkResult(result)
+		}
+	}
+	failed := results[firstFailed]
+	checkResult(failed)
+	fmt.Println(color.YellowString("Cancelled %s since %s failed",
+		strings.Join(cancelled, ", "), path.Base(failed.executable)))
+	return true
+}
@@ -0,0 +1,68 @@
+package hooks
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapBoundedDoesntWaitForJobsOnceCancelled(t *testing.T) {
+	// A jobserver whose only job is always taken.
+	full := &jobserver{local: make(chan struct{}, 1), implicit: make(chan struct{}, 1)}
+	full.local <- struct{}{}
+	previous := jobs
+	jobs = func() *jobserver { return full }
+	defer func() { jobs = previous }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan []hookResult)
+	go func() {
+		done <- mapBounded(ctx, nil, []string{"first", "second"}, func(executable string, _ int) hookResult {
+			return hookResult{executable: executable, cancelled: true}
+		})
+	}()
+	select {
+	case results := <-done:
+		assert.Len(t, results, 2)
+	case <-time.After(5 * time.Second):
+		t.Fatal("mapBounded waited for a job after being cancelled")
+	}
+}
+
+func TestMapBoundedStopsWaitingForJobsWhenCancelled(t *testing.T) {
+	// A jobserver whose only job is always taken.
+	full := &jobserver{local: make(chan struct{}, 1), implicit: make(chan struct{}, 1)}
+	full.local <- struct{}{}
+	// And one with room locally, but no tokens to read from its pipe.
+	read, write, err := os.Pipe()
+	require.NoError(t, err)
+	defer write.Close()
+	empty := &jobserver{local: make(chan struct{}, 2), implicit: make(chan struct{}, 1), read: read, write: write}
+
+	previous := jobs
+	defer func() { jobs = previous }()
+	for name, server := range map[string]*jobserver{"full": full, "empty pipe": empty} {
+		jobs = func() *jobserver { return server }
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan []hookResult)
+		go func() {
+			done <- mapBounded(ctx, nil, []string{"first", "second"}, func(executable string, _ int) hookResult {
+				return hookResult{executable: executable, cancelled: true}
+			})
+		}()
+		// Give it time to block waiting for a job.
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		select {
+		case results := <-done:
+			assert.Len(t, results, 2, name)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("mapBounded kept waiting for a job after being cancelled (%v)", name)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package hooks
+
+import (
+	"io"
+	"os"
+
+	"github.com/dirk/quickhook/repo"
+)
+
+// Hooks from githooks(5) which Quickhook can run. Some hooks (proc-receive and
+// fsmonitor-watchman) are left out since Git reads their stdout as a protocol, which doesn't
+// work with running multiple executables.
+var GIT_HOOKS = []string{
+	"applypatch-msg",
+	"pre-applypatch",
+	"post-applypatch",
+	"pre-commit",
+	"pre-merge-commit",
+	"prepare-commit-msg",
+	"commit-msg",
+	"post-commit",
+	"pre-rebase",
+	"post-checkout",
+	"post-merge",
+	"pre-push",
+	"pre-receive",
+	"update",
+	"post-receive",
+	"post-update",
+	"reference-transaction",
+	"push-to-checkout",
+	"pre-auto-gc",
+	"post-rewrite",
+	"sendemail-validate",
+	"p4-changelist",
+	"p4-prepare-changelist",
+	"p4-post-changelist",
+	"p4-pre-submit",
+	"post-index-change",
+}
+
+// Runs any hook without first-class support by passing Git's arguments and stdin through
+// unchanged to each executable, one after another.
+type Passthrough struct {
+	Repo *repo.Repo
+}
+
+func (hook *Passthrough) Run(name string, arg []string, stdin *os.File) error {
+	executables, err := hook.Repo.FindHookExecutables(name)
+	if err != nil {
+		return err
+	}
+	if len(executables) == 0 {
+		return nil
+	}
+
+	input := ""
+	// Only read stdin if it's been redirected, otherwise (eg. when running a hook manually in a
+	// terminal) this would wait for input that's never coming.
+	if info, err := stdin.Stat(); err == nil && (info.Mode()&os.ModeCharDevice) == 0 {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		input = string(data)
+	}
+
+	for _, executable := range executables {
+		result := runExecutable(hook.Repo.Root, executable, []string{}, input, arg...)
+		if checkResult(result) {
+			return ErrFailed
+		}
+	}
+	return nil
+}
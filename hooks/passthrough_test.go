@@ -0,0 +1,49 @@
+package hooks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassthroughForwardsArgsAndStdin(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "post-rewrite")
+	tempDir.WriteFile(
+		[]string{".quickhook", "post-rewrite", "first"},
+		"#!/bin/sh \n echo \"$# $@\" \n cat \n exit 1")
+
+	cmd := tempDir.NewCommand(tempDir.Quickhook, "hook", "run", "post-rewrite", "--", "--amend", "two words")
+	cmd.Stdin = strings.NewReader("abc123 def456\n")
+	output, err := cmd.CombinedOutput()
+	assert.Error(t, err)
+	assert.Equal(t, "first: 2 --amend two words\nfirst: abc123 def456\n", string(output))
+}
+
+func TestPassthroughShimRunsHooks(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.RequireExec("git", "commit", "--quiet", "--no-verify", "--message", "Commit example.txt")
+	tempDir.RequireExec("git", "branch", "feature")
+	tempDir.RequireExec("git", "commit", "--quiet", "--no-verify", "--allow-empty", "--message", "Empty")
+	tempDir.RequireExec("git", "checkout", "--quiet", "feature")
+	tempDir.MkdirAll(".quickhook", "pre-rebase")
+	tempDir.WriteFile(
+		[]string{".quickhook", "pre-rebase", "refuses"},
+		"#!/bin/sh \n echo \"refusing to rebase onto $1\" \n exit 1")
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--bin="+tempDir.Quickhook)
+	require.NoError(t, err)
+
+	output, err := tempDir.NewCommand("git", "rebase", "main").CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(output), "refuses: refusing to rebase onto main\n")
+}
+
+func TestPassthroughRejectsUnknownHooks(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+
+	output, err := tempDir.ExecQuickhook("hook", "run", "pre-everything")
+	assert.Error(t, err)
+	assert.Contains(t, output, "unknown Git hook: pre-everything")
+}
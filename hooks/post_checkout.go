@@ -0,0 +1,37 @@
+package hooks
+
+import (
+	"github.com/dirk/quickhook/repo"
+)
+
+const POST_CHECKOUT_HOOK = "post-checkout"
+
+type PostCheckout struct {
+	Repo *repo.Repo
+}
+
+// The arguments are the ones Git passes to the post-checkout hook: the previous HEAD, the new
+// HEAD, and a flag which is "1" if it was a branch checkout or "0" if it was a file checkout.
+func (hook *PostCheckout) Run(previousHead, newHead, flag string) error {
+	diffBase := previousHead
+	if repo.IsNullSHA(previousHead) {
+		// The previous HEAD is null when cloning, so compare with nothing instead.
+		emptyTree, err := hook.Repo.EmptyTree()
+		if err != nil {
+			return err
+		}
+		diffBase = emptyTree
+	}
+	files, err := hook.Repo.DiffNames(diffBase, newHead)
+	if err != nil {
+		return err
+	}
+
+	checkoutType := "file"
+	if flag == "1" {
+		checkoutType = "branch"
+	}
+	env := []string{"QUICKHOOK_CHECKOUT_TYPE=" + checkoutType}
+	// Pass the arguments through as Git gave them, including a null previous HEAD.
+	return runWatchingExecutables(hook.Repo, POST_CHECKOUT_HOOK, files, env, previousHead, newHead, flag)
+}
@@ -0,0 +1,92 @@
+package hooks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dirk/quickhook/internal/test"
+)
+
+func initGitForPostChange(t *testing.T, hook string) test.TempDir {
+	tempDir := initGitForPreCommit(t)
+	tempDir.WriteFile([]string{"go.mod"}, "module example")
+	tempDir.RequireExec("git", "add", "go.mod")
+	tempDir.RequireExec("git", "commit", "--quiet", "--no-verify", "--message", "Initial commit")
+	tempDir.RequireExec("git", "branch", "go-mod")
+	tempDir.RequireExec("git", "branch", "example")
+
+	tempDir.RequireExec("git", "checkout", "--quiet", "go-mod")
+	tempDir.WriteFile([]string{"go.mod"}, "module example\n\ngo 1.21")
+	tempDir.RequireExec("git", "commit", "--quiet", "--no-verify", "--all", "--message", "Change go.mod")
+	tempDir.RequireExec("git", "checkout", "--quiet", "example")
+	tempDir.WriteFile([]string{"example.txt"}, "Changed again!")
+	tempDir.RequireExec("git", "commit", "--quiet", "--no-verify", "--all", "--message", "Change example.txt")
+	tempDir.RequireExec("git", "checkout", "--quiet", "main")
+
+	tempDir.MkdirAll(".quickhook", hook)
+	tempDir.WriteFile(
+		[]string{".quickhook", hook, "downloads"},
+		"#!/bin/sh \n # quickhook-watch: go.mod go.sum \n echo \"downloading for $(cat)\" 1>&2")
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--bin="+tempDir.Quickhook)
+	require.NoError(t, err)
+	return tempDir
+}
+
+func TestPostCheckoutRunsWhenWatchedFilesChange(t *testing.T) {
+	tempDir := initGitForPostChange(t, "post-checkout")
+	tempDir.WriteFile(
+		[]string{".quickhook", "post-checkout", "always"},
+		"#!/bin/sh \n echo \"$QUICKHOOK_CHECKOUT_TYPE checkout\" 1>&2")
+
+	output, err := tempDir.NewCommand("git", "checkout", "--quiet", "example").CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t, "always: branch checkout\n", string(output))
+
+	output, err = tempDir.NewCommand("git", "checkout", "--quiet", "main", "--", "example.txt").CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t, "always: file checkout\n", string(output))
+
+	output, err = tempDir.NewCommand("git", "checkout", "--quiet", "go-mod").CombinedOutput()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t,
+		[]string{"always: branch checkout", "downloads: downloading for example.txt", "downloads: go.mod"},
+		strings.Split(strings.TrimSpace(string(output)), "\n"))
+}
+
+func TestPostCheckoutPassesNullPreviousHead(t *testing.T) {
+	tempDir := initGitForPostChange(t, "post-checkout")
+	tempDir.WriteFile(
+		[]string{".quickhook", "post-checkout", "always"},
+		"#!/bin/sh \n echo \"previous $1\" 1>&2")
+
+	nullSHA := strings.Repeat("0", 40)
+	output, err := tempDir.ExecQuickhook("hook", "post-checkout", nullSHA, "HEAD", "1")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "always: previous "+nullSHA+"\n")
+	// Everything is compared with the empty tree, so go.mod counts as changed.
+	assert.Contains(t, output, "downloads: go.mod")
+}
+
+func TestPostMergeRunsWhenWatchedFilesChange(t *testing.T) {
+	tempDir := initGitForPostChange(t, "post-merge")
+
+	output, err := tempDir.NewCommand("git", "merge", "--quiet", "example").CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(output))
+
+	output, err = tempDir.NewCommand("git", "merge", "--quiet", "--no-edit", "go-mod").CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t, "downloads: downloading for go.mod\n", string(output))
+}
+
+func TestAnyFileMatches(t *testing.T) {
+	assert.True(t, anyFileMatches([]string{"go.mod"}, []string{"example.txt", "go.mod"}))
+	assert.True(t, anyFileMatches([]string{"go.mod"}, []string{"tools/go.mod"}))
+	assert.True(t, anyFileMatches([]string{"*.proto"}, []string{"api/service.proto"}))
+	assert.True(t, anyFileMatches([]string{"api/*.proto"}, []string{"api/service.proto"}))
+	assert.False(t, anyFileMatches([]string{"api/*.proto"}, []string{"other/service.proto"}))
+	assert.False(t, anyFileMatches([]string{"go.mod"}, []string{}))
+}
@@ -0,0 +1,99 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/dirk/quickhook/repo"
+)
+
+const POST_COMMIT_HOOK = "post-commit"
+
+type PostCommit struct {
+	Repo *repo.Repo
+}
+
+// Starts a detached process (Quickhook itself, running `hook post-commit --foreground`) which runs
+// the hooks in the background and writes their output to a new log file. Returns as soon as the
+// process has started.
+func (hook *PostCommit) Run() error {
+	executables, err := hook.Repo.FindHookExecutables(POST_COMMIT_HOOK)
+	if err != nil {
+		return err
+	}
+	if len(executables) == 0 {
+		return nil
+	}
+
+	log, err := newLogFile(hook.Repo, POST_COMMIT_HOOK)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+
+	quickhook, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(quickhook, append(runFlags(), "hook", POST_COMMIT_HOOK, "--foreground")...)
+	cmd.Dir = hook.Repo.Root
+	cmd.Stdout = log
+	cmd.Stderr = log
+	// Start a new session so that the process isn't tied to the terminal.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// Returns the flags which set the options for running hooks (eg. --timeout) to their values for
+// this run, so that another Quickhook process runs hooks the same way.
+func runFlags() []string {
+	flags := []string{}
+	if DefaultTimeout > 0 {
+		flags = append(flags, "--timeout="+DefaultTimeout.String())
+	}
+	if FailFast {
+		flags = append(flags, "--fail-fast")
+	}
+	if Jobs > 0 {
+		flags = append(flags, fmt.Sprintf("--jobs=%d", Jobs))
+	}
+	return flags
+}
+
+// Runs the hooks in parallel and prints all of their output, followed by whether they passed.
+func (hook *PostCommit) RunForeground() error {
+	executables, err := hook.Repo.FindHookExecutables(POST_COMMIT_HOOK)
+	if err != nil {
+		return err
+	}
+	commit, err := hook.Repo.RevParse("HEAD")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Running %s hooks for %s at %s\n", POST_COMMIT_HOOK, commit, time.Now().Format(time.RFC3339))
+
+	history := loadHistory(hook.Repo, POST_COMMIT_HOOK)
+	results := mapBounded(context.Background(), history, executables, func(executable string, _ int) hookResult {
+		return runExecutable(hook.Repo.Root, executable, []string{}, "")
+	})
+	errored := false
+	for _, result := range results {
+		result.printStderr()
+		result.printStdout()
+		errored = result.err != nil || errored
+	}
+	if errored {
+		fmt.Printf("%s failed\n", POST_COMMIT_HOOK)
+		return ErrFailed
+	}
+	fmt.Printf("%s passed\n", POST_COMMIT_HOOK)
+	return nil
+}
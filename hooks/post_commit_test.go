@@ -0,0 +1,74 @@
+package hooks
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostCommitRunsInBackground(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "post-commit")
+	tempDir.WriteFile(
+		[]string{".quickhook", "post-commit", "notifies"},
+		"#!/bin/sh \n sleep 2 \n echo \"notified\"")
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--bin="+tempDir.Quickhook)
+	require.NoError(t, err)
+
+	start := time.Now()
+	tempDir.RequireExec("git", "commit", "--quiet", "--message", "Commit example.txt")
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	var output string
+	require.Eventually(t, func() bool {
+		output, err = tempDir.ExecQuickhook("logs")
+		require.NoError(t, err)
+		return strings.Contains(output, "post-commit passed")
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.Contains(t, output, "Running post-commit hooks for ")
+	assert.Contains(t, output, "notifies: notified\n")
+}
+
+func TestPostCommitPassesFlagsToBackgroundProcess(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "post-commit")
+	tempDir.WriteFile(
+		[]string{".quickhook", "post-commit", "slow"},
+		"#!/bin/sh \n sleep 5 \n echo \"finished\"")
+	tempDir.RequireExec("git", "commit", "--quiet", "--no-verify", "--message", "Commit example.txt")
+
+	_, err := tempDir.ExecQuickhook("--timeout=200ms", "hook", "post-commit")
+	require.NoError(t, err)
+
+	var output string
+	require.Eventually(t, func() bool {
+		output, err = tempDir.ExecQuickhook("logs")
+		require.NoError(t, err)
+		return strings.Contains(output, "post-commit failed")
+	}, 4*time.Second, 100*time.Millisecond)
+	assert.NotContains(t, output, "finished")
+}
+
+func TestPostCommitForegroundReportsFailure(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.RequireExec("git", "commit", "--quiet", "--no-verify", "--message", "Commit example.txt")
+	tempDir.MkdirAll(".quickhook", "post-commit")
+	tempDir.WriteFile(
+		[]string{".quickhook", "post-commit", "fails"},
+		"#!/bin/sh \n echo \"failed\" \n exit 1")
+
+	output, err := tempDir.ExecQuickhook("hook", "post-commit", "--foreground")
+	assert.Error(t, err)
+	assert.Contains(t, output, "fails: failed\npost-commit failed\n")
+}
+
+func TestLogsWithNoLogs(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+
+	output, err := tempDir.ExecQuickhook("logs")
+	assert.NoError(t, err)
+	assert.Equal(t, "No logs found\n", output)
+}
@@ -0,0 +1,28 @@
+package hooks
+
+import (
+	"github.com/dirk/quickhook/repo"
+)
+
+const POST_MERGE_HOOK = "post-merge"
+
+type PostMerge struct {
+	Repo *repo.Repo
+}
+
+// The squash flag is the argument Git passes to the post-merge hook: "1" if it was a squash
+// merge, "0" if not.
+func (hook *PostMerge) Run(squash string) error {
+	var files []string
+	var err error
+	if squash == "1" {
+		// Squash merges don't create a commit, so the changes are only in the index.
+		files, err = hook.Repo.DiffNames("--cached")
+	} else {
+		files, err = hook.Repo.DiffNames("ORIG_HEAD", "HEAD")
+	}
+	if err != nil {
+		return err
+	}
+	return runWatchingExecutables(hook.Repo, POST_MERGE_HOOK, files, []string{}, squash)
+}
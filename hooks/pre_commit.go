@@ -1,14 +1,16 @@
 package hooks
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
 	"os"
 	"os/exec"
 	"path"
 	"strings"
+	"sync"
 
-	lop "github.com/samber/lo/parallel"
+	"github.com/samber/lo"
 
 	"github.com/dirk/quickhook/internal"
 	"github.com/dirk/quickhook/repo"
@@ -24,8 +26,24 @@ const PRE_COMMIT_MUTATING_HOOK = "pre-commit-mutating"
 const FAILED_EXIT_CODE = 65         // EX_DATAERR - hooks didn't pass
 const NOTHING_STAGED_EXIT_CODE = 66 // EX_NOINPUT
 
+// Default maximum number of files passed on stdin to a single execution of a hook when running on
+// all files or a range.
+const DEFAULT_BATCH_SIZE = 1000
+
 type PreCommit struct {
 	Repo *repo.Repo
+	// Run on all Git-tracked files rather than just the ones to be committed.
+	All bool
+	// If FromRef is set then run on the files changed between it and ToRef rather than the ones
+	// to be committed.
+	FromRef string
+	ToRef   string
+	// If Commit is set then run on the files changed by that commit (which may be none).
+	Commit string
+	// Maximum number of files to pass to each execution of a hook; if there are more files than
+	// this then the hook will be executed multiple times. If zero then it's DEFAULT_BATCH_SIZE with
+	// All or FromRef, otherwise the files aren't batched.
+	BatchSize int
 }
 
 // argsFiles can be non-empty with the files passed in by the user when manually running this hook,
@@ -37,13 +55,20 @@ func (hook *PreCommit) Run(argsFiles []string) error {
 	if err != nil {
 		return err
 	}
-	defer os.RemoveAll(dirForPath)
+	defer internal.AddCleanup(func() { os.RemoveAll(dirForPath) })()
 
 	files, mutatingExecutables, parallelExecutables, err := internal.FanOut3(
 		func() ([]string, error) {
 			if len(argsFiles) > 0 {
 				return argsFiles, nil
 			}
+			if hook.streamsFiles() {
+				// There can be a lot of these, so they're read from Git in batches by batches().
+				return nil, nil
+			}
+			if hook.Commit != "" {
+				return hook.Repo.FilesChangedInCommit(hook.Commit)
+			}
 			if files, err := hook.Repo.FilesToBeCommitted(); err != nil {
 				return nil, err
 			} else {
@@ -61,42 +86,95 @@ func (hook *PreCommit) Run(argsFiles []string) error {
 		return err
 	}
 
-	stdin := strings.Join(files, "\n")
+	batches := hook.batches(argsFiles, files)
 
 	// Run mutating executables sequentially.
 	for _, executable := range mutatingExecutables {
-		result := runExecutable(hook.Repo.Root, executable, os.Environ(), stdin)
-		if hook.checkResult(result) {
-			os.Exit(FAILED_EXIT_CODE)
+		result, err := runExecutableBatches(context.Background(), hook.Repo.Root, executable, os.Environ(), batches)
+		if err != nil {
+			return err
+		}
+		if checkResult(result) {
+			return ErrFailed
 		}
 	}
 	// And the rest in parallel.
-	results := lop.Map(parallelExecutables, func(executable string, _ int) hookResult {
+	history := loadHistory(hook.Repo, PRE_COMMIT_HOOK)
+	var batchesErr error
+	var batchesErrOnce sync.Once
+	results, firstFailed := mapParallel(history, parallelExecutables, func(ctx context.Context, executable string) hookResult {
 		// Insert the git shim's directory into the PATH to prevent usage of git.
 		env := append(os.Environ(), fmt.Sprintf("PATH=%s:%s", dirForPath, os.Getenv("PATH")))
-		return runExecutable(hook.Repo.Root, executable, env, stdin)
+		result, err := runExecutableBatches(ctx, hook.Repo.Root, executable, env, batches)
+		if err != nil {
+			batchesErrOnce.Do(func() { batchesErr = err })
+		}
+		return result
 	})
-	errored := false
-	for _, result := range results {
-		errored = hook.checkResult(result) || errored
+	if batchesErr != nil {
+		return batchesErr
 	}
-	if errored {
-		os.Exit(FAILED_EXIT_CODE)
+	if checkParallelResults(results, firstFailed) {
+		return ErrFailed
 	}
 	return nil
 }
 
-// Returns true if the hook errored, false if it did not.
-func (hook *PreCommit) checkResult(result hookResult) bool {
-	if result.err == nil {
-		// Print any stderr even if the hook executable succeeded.
-		result.printStderr()
-		return false
+// Calls each with a batch of files to pass to an execution of a hook, stopping if it returns an
+// error. There's always at least one batch so that hooks still run when there are no files.
+type fileBatches func(each func(files []string) error) error
+
+// Files from --all and ranges are read from Git as they're needed rather than being kept in memory.
+func (hook *PreCommit) streamsFiles() bool {
+	return hook.All || hook.FromRef != ""
+}
+
+// Returns the batches of files to run the hooks on. With --all or a range the files are batched
+// by DEFAULT_BATCH_SIZE unless BatchSize is set; otherwise they're only batched if it's set.
+func (hook *PreCommit) batches(argsFiles, files []string) fileBatches {
+	if len(argsFiles) > 0 || !hook.streamsFiles() {
+		return fixedBatches(files, hook.BatchSize)
+	}
+	size := hook.BatchSize
+	if size <= 0 {
+		size = DEFAULT_BATCH_SIZE
+	}
+	// The files are listed again for each hook so that only one batch is in memory per hook.
+	return atLeastOneBatch(func(each func(files []string) error) error {
+		if hook.All {
+			return hook.Repo.TrackedFileBatches(size, each)
+		}
+		return hook.Repo.FilesChangedBetweenBatches(hook.FromRef, hook.ToRef, size, each)
+	})
+}
+
+// Splits the files into batches of at most size files, or a single batch if size is zero.
+func fixedBatches(files []string, size int) fileBatches {
+	return atLeastOneBatch(func(each func(files []string) error) error {
+		if size <= 0 || len(files) <= size {
+			return each(files)
+		}
+		for _, batch := range lo.Chunk(files, size) {
+			if err := each(batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func atLeastOneBatch(batches fileBatches) fileBatches {
+	return func(each func(files []string) error) error {
+		called := false
+		err := batches(func(files []string) error {
+			called = true
+			return each(files)
+		})
+		if err == nil && !called {
+			err = each([]string{})
+		}
+		return err
 	}
-	// Maybe print a header?
-	result.printStderr()
-	result.printStdout()
-	return true
 }
 
 func shimGit() (string, error) {
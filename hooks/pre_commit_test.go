@@ -2,7 +2,10 @@ package hooks
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"os"
+	"path"
 	"sort"
 	"strings"
 	"testing"
@@ -195,3 +198,122 @@ func TestMutatingCanAccessGit(t *testing.T) {
 		})
 	}
 }
+
+func TestAllRunsOnTrackedFiles(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.WriteFile([]string{"other-example.txt"}, "Also changed!")
+	tempDir.RequireExec("git", "add", "other-example.txt")
+	tempDir.RequireExec("git", "commit", "--message", "Commit examples", "--quiet", "--no-verify")
+	tempDir.WriteFile([]string{"untracked.txt"}, "Not tracked")
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "lists"}, "#!/bin/sh \n tr '\\n' ' ' \n exit 1")
+
+	output, err := tempDir.ExecQuickhook("hook", "pre-commit", "--all")
+	assert.Error(t, err)
+	assert.Equal(t, "lists: example.txt other-example.txt\n", output)
+}
+
+func TestAllRunsInBatches(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.WriteFile([]string{"other-example.txt"}, "Also changed!")
+	tempDir.WriteFile([]string{"third-example.txt"}, "Changed too!")
+	tempDir.RequireExec("git", "add", "other-example.txt", "third-example.txt")
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "lists"}, "#!/bin/sh \n tr '\\n' ' ' \n exit 1")
+
+	output, err := tempDir.ExecQuickhook("hook", "pre-commit", "--all", "--batch-size=2")
+	assert.Error(t, err)
+	assert.Equal(t, "lists: example.txt other-example.txt\nlists: third-example.txt\n", output)
+}
+
+func TestStagedFilesArentBatchedByDefault(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	for index := 0; index < DEFAULT_BATCH_SIZE; index++ {
+		tempDir.WriteFile([]string{fmt.Sprintf("file-%d.txt", index)}, "Changed!")
+	}
+	tempDir.RequireExec("git", "add", ".")
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "counts"}, "#!/bin/sh \n wc -l | tr -d ' ' >> counts.txt")
+
+	_, err := tempDir.ExecQuickhook("hook", "pre-commit")
+	require.NoError(t, err)
+	data, err := os.ReadFile(path.Join(tempDir.Root, "counts.txt"))
+	require.NoError(t, err)
+	// One execution with every file (including example.txt, but wc doesn't count the last line
+	// since it doesn't end with a newline).
+	assert.Equal(t, fmt.Sprintf("%d\n", DEFAULT_BATCH_SIZE), string(data))
+}
+
+func TestAllAndFilesAreExclusive(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+
+	output, err := tempDir.ExecQuickhook("hook", "pre-commit", "--all", "--files=example.txt")
+	assert.Error(t, err)
+	assert.Contains(t, output, "can't be used together")
+}
+
+func initGitForRange(t *testing.T) test.TempDir {
+	tempDir := initGitForPreCommit(t)
+	tempDir.RequireExec("git", "commit", "--message", "Commit example.txt", "--quiet", "--no-verify")
+	tempDir.RequireExec("git", "checkout", "--quiet", "-b", "feature")
+	tempDir.WriteFile([]string{"other-example.txt"}, "Also changed!")
+	tempDir.RequireExec("git", "add", "other-example.txt")
+	tempDir.RequireExec("git", "rm", "example.txt", "--quiet")
+	tempDir.RequireExec("git", "commit", "--message", "Change files", "--quiet", "--no-verify")
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "lists"}, "#!/bin/sh \n tr '\\n' ' ' \n exit 1")
+	return tempDir
+}
+
+func TestRangeRunsOnChangedFiles(t *testing.T) {
+	argsTests := []struct {
+		name string
+		arg  []string
+	}{
+		{"range", []string{"--range=main..feature"}},
+		{"from-ref", []string{"--from-ref=main"}},
+		{"from-ref and to-ref", []string{"--from-ref=main", "--to-ref=feature"}},
+	}
+	for _, tt := range argsTests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := initGitForRange(t)
+
+			output, err := tempDir.ExecQuickhook(append([]string{"hook", "pre-commit"}, tt.arg...)...)
+			assert.Error(t, err)
+			// Deleted example.txt should not be included.
+			assert.Equal(t, "lists: other-example.txt\n", output)
+		})
+	}
+}
+
+func TestRangeRequiresFromRef(t *testing.T) {
+	tempDir := initGitForRange(t)
+
+	output, err := tempDir.ExecQuickhook("hook", "pre-commit", "--to-ref=feature")
+	assert.Error(t, err)
+	assert.Contains(t, output, "--to-ref requires --from-ref")
+}
+
+func TestRangeExcludesFilesDeletedFromWorkingTree(t *testing.T) {
+	tempDir := initGitForRange(t)
+	tempDir.WriteFile([]string{"third-example.txt"}, "Changed too!")
+	tempDir.RequireExec("git", "add", "third-example.txt")
+	tempDir.RequireExec("git", "commit", "--message", "Add third-example.txt", "--quiet", "--no-verify")
+	require.NoError(t, os.Remove(path.Join(tempDir.Root, "other-example.txt")))
+
+	output, err := tempDir.ExecQuickhook("hook", "pre-commit", "--from-ref=main")
+	assert.Error(t, err)
+	assert.Equal(t, "lists: third-example.txt\n", output)
+}
+
+func TestRangeRejectsOptionLikeRefs(t *testing.T) {
+	tempDir := initGitForRange(t)
+
+	for _, arg := range []string{"--from-ref=--output=out.txt", "--range=--output=out.txt..HEAD"} {
+		output, err := tempDir.ExecQuickhook("hook", "pre-commit", arg)
+		assert.Error(t, err)
+		assert.Contains(t, output, "invalid ref (can't start with \"-\"): --output=out.txt")
+	}
+	assert.NoFileExists(t, path.Join(tempDir.Root, "out.txt"))
+}
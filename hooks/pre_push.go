@@ -0,0 +1,98 @@
+package hooks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/dirk/quickhook/repo"
+)
+
+const PRE_PUSH_HOOK = "pre-push"
+
+// One of the lines Git passes to the pre-push hook on stdin describing a ref being pushed.
+type PushUpdate struct {
+	LocalRef  string
+	LocalSHA  string
+	RemoteRef string
+	RemoteSHA string
+}
+
+// Returns true if the push is deleting the remote ref.
+func (update PushUpdate) IsDelete() bool {
+	return repo.IsNullSHA(update.LocalSHA)
+}
+
+// Parses the "<local ref> <local sha> <remote ref> <remote sha>" lines Git passes on stdin.
+func ParsePushUpdates(input string) ([]PushUpdate, error) {
+	updates := []PushUpdate{}
+	scanner := bufio.NewScanner(strings.NewReader(input))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid pre-push line: %v", line)
+		}
+		updates = append(updates, PushUpdate{
+			LocalRef:  fields[0],
+			LocalSHA:  fields[1],
+			RemoteRef: fields[2],
+			RemoteSHA: fields[3],
+		})
+	}
+	return updates, scanner.Err()
+}
+
+type PrePush struct {
+	Repo *repo.Repo
+}
+
+// Runs the pre-push executables in parallel. They receive the files changed across all of the
+// pushed commits on stdin, the remote name and URL as arguments (like Git hooks), and the path to
+// a file containing the ref updates from Git in QUICKHOOK_PUSH_UPDATES.
+func (hook *PrePush) Run(remote, url string, stdin io.Reader) error {
+	input, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	updates, err := ParsePushUpdates(string(input))
+	if err != nil {
+		return err
+	}
+
+	executables, err := hook.Repo.FindHookExecutables(PRE_PUSH_HOOK)
+	if err != nil {
+		return err
+	}
+	if len(executables) == 0 {
+		return nil
+	}
+
+	files := []string{}
+	for _, update := range updates {
+		if update.IsDelete() {
+			continue
+		}
+		changed, err := hook.Repo.FilesChangedInPush(remote, update.LocalSHA, update.RemoteSHA)
+		if err != nil {
+			return err
+		}
+		files = append(files, changed...)
+	}
+	files = lo.Uniq(files)
+
+	updatesFile, removeUpdatesFile, err := writeTempFile("quickhook-push-*", input)
+	if err != nil {
+		return err
+	}
+	defer removeUpdatesFile()
+
+	env := []string{"QUICKHOOK_PUSH_UPDATES=" + updatesFile}
+	return runParallel(hook.Repo, PRE_PUSH_HOOK, executables, env, strings.Join(files, "\n"), remote, url)
+}
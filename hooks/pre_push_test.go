@@ -0,0 +1,90 @@
+package hooks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dirk/quickhook/internal/test"
+)
+
+func initGitForPrePush(t *testing.T) test.TempDir {
+	remote := t.TempDir()
+	tempDir := test.NewTempDir(t, 1)
+	tempDir.RequireExec("git", "init", "--bare", "--quiet", remote)
+	tempDir.RequireExec("git", "init", "--initial-branch=main", "--quiet", ".")
+	tempDir.RequireExec("git", "config", "--local", "user.name", "example")
+	tempDir.RequireExec("git", "config", "--local", "user.email", "example@example.com")
+	tempDir.RequireExec("git", "remote", "add", "origin", remote)
+	tempDir.WriteFile([]string{"example.txt"}, "Changed!")
+	tempDir.RequireExec("git", "add", "example.txt")
+	tempDir.RequireExec("git", "commit", "--message", "Commit example.txt", "--quiet", "--no-verify")
+	tempDir.RequireExec("git", "push", "--quiet", "--no-verify", "origin", "main")
+
+	tempDir.MkdirAll(".quickhook", "pre-push")
+	tempDir.WriteFile(
+		[]string{".quickhook", "pre-push", "lists"},
+		"#!/bin/sh \n echo \"remote: $1\" \n tr '\\n' ' ' \n echo \n cut -d' ' -f1,3 $QUICKHOOK_PUSH_UPDATES \n exit 1")
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--bin="+tempDir.Quickhook)
+	require.NoError(t, err)
+	return tempDir
+}
+
+func TestPrePushReceivesPushedFiles(t *testing.T) {
+	tempDir := initGitForPrePush(t)
+	tempDir.WriteFile([]string{"other-example.txt"}, "Also changed!")
+	tempDir.RequireExec("git", "add", "other-example.txt")
+	tempDir.RequireExec("git", "commit", "--message", "Commit other-example.txt", "--quiet", "--no-verify")
+
+	output, err := tempDir.NewCommand("git", "push", "--quiet", "origin", "main").CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(output),
+		"lists: remote: origin\nlists: other-example.txt\nlists: refs/heads/main refs/heads/main\n")
+}
+
+func TestPrePushReceivesFilesForNewBranch(t *testing.T) {
+	tempDir := initGitForPrePush(t)
+	tempDir.RequireExec("git", "checkout", "--quiet", "-b", "feature")
+	tempDir.WriteFile([]string{"feature.txt"}, "New feature")
+	tempDir.RequireExec("git", "add", "feature.txt")
+	tempDir.RequireExec("git", "commit", "--message", "Commit feature.txt", "--quiet", "--no-verify")
+
+	output, err := tempDir.NewCommand("git", "push", "--quiet", "origin", "feature").CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(output),
+		"lists: remote: origin\nlists: feature.txt\nlists: refs/heads/feature refs/heads/feature\n")
+}
+
+func TestPrePushToURLReceivesFilesForNewBranch(t *testing.T) {
+	tempDir := initGitForPrePush(t)
+	url, err := tempDir.NewCommand("git", "remote", "get-url", "origin").Output()
+	require.NoError(t, err)
+	remote := strings.TrimSpace(string(url))
+	tempDir.RequireExec("git", "checkout", "--quiet", "-b", "feature")
+	tempDir.WriteFile([]string{"feature.txt"}, "New feature")
+	tempDir.RequireExec("git", "add", "feature.txt")
+	tempDir.RequireExec("git", "commit", "--message", "Commit feature.txt", "--quiet", "--no-verify")
+
+	output, err := tempDir.NewCommand("git", "push", "--quiet", remote, "feature").CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(output),
+		"lists: remote: "+remote+"\nlists: feature.txt\nlists: refs/heads/feature refs/heads/feature\n")
+}
+
+func TestParsePushUpdates(t *testing.T) {
+	updates, err := ParsePushUpdates(
+		"refs/heads/main 67890 refs/heads/main 12345\n" +
+			"(delete) 0000000000000000000000000000000000000000 refs/heads/old 12345\n")
+	assert.NoError(t, err)
+	assert.Equal(t, []PushUpdate{
+		{"refs/heads/main", "67890", "refs/heads/main", "12345"},
+		{"(delete)", "0000000000000000000000000000000000000000", "refs/heads/old", "12345"},
+	}, updates)
+	assert.False(t, updates[0].IsDelete())
+	assert.True(t, updates[1].IsDelete())
+
+	_, err = ParsePushUpdates("refs/heads/main 67890\n")
+	assert.Error(t, err)
+}
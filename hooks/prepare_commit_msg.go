@@ -0,0 +1,36 @@
+package hooks
+
+import (
+	"github.com/dirk/quickhook/repo"
+)
+
+const PREPARE_COMMIT_MSG_HOOK = "prepare-commit-msg"
+
+type PrepareCommitMsg struct {
+	Repo *repo.Repo
+}
+
+// source and sha are optional and are only passed on to the executables if Git provided them.
+func (hook *PrepareCommitMsg) Run(messageFile, source, sha string) error {
+	executables, err := hook.Repo.FindHookExecutables(PREPARE_COMMIT_MSG_HOOK)
+	if err != nil {
+		return err
+	}
+	arg := []string{messageFile}
+	if source != "" {
+		arg = append(arg, source)
+		if sha != "" {
+			arg = append(arg, sha)
+		}
+	}
+	for _, executable := range executables {
+		result := runExecutable(hook.Repo.Root, executable, []string{}, "", arg...)
+		if result.err == nil {
+			continue
+		}
+		result.printStderr()
+		result.printStdout()
+		return ErrFailed
+	}
+	return nil
+}
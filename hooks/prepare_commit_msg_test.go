@@ -0,0 +1,54 @@
+package hooks
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareCommitMsgReceivesSourceAndSHA(t *testing.T) {
+	tempDir := initGitForCommitMsg(t)
+	tempDir.MkdirAll(".quickhook", "prepare-commit-msg")
+	tempDir.WriteFile(
+		[]string{".quickhook", "prepare-commit-msg", "appends"},
+		"#!/bin/sh \n echo \"$2 $3\" >> $1")
+
+	editMsgFile := writeCommitEditMsg(t, "First\n")
+	_, err := tempDir.ExecQuickhook("hook", "prepare-commit-msg", editMsgFile, "commit", "abc123")
+	assert.NoError(t, err)
+
+	newEditMsg, err := os.ReadFile(editMsgFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "First\ncommit abc123\n", string(newEditMsg))
+}
+
+func TestPrepareCommitMsgPrefillsCommit(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "prepare-commit-msg")
+	tempDir.WriteFile(
+		[]string{".quickhook", "prepare-commit-msg", "prefixes"},
+		"#!/bin/sh \n printf \"[TICKET-1] ($2) %s\" \"$(cat $1)\" > $1")
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--bin="+tempDir.Quickhook)
+	require.NoError(t, err)
+
+	tempDir.RequireExec("git", "commit", "--quiet", "--message", "Commit example.txt")
+
+	subject, err := tempDir.NewCommand("git", "log", "-1", "--format=%s").Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "[TICKET-1] (message) Commit example.txt", strings.TrimSpace(string(subject)))
+}
+
+func TestFailingPrepareCommitMsgHook(t *testing.T) {
+	tempDir := initGitForCommitMsg(t)
+	tempDir.MkdirAll(".quickhook", "prepare-commit-msg")
+	tempDir.WriteFile(
+		[]string{".quickhook", "prepare-commit-msg", "fails"},
+		"#!/bin/sh \n echo \"failed\" \n exit 1")
+
+	output, err := tempDir.ExecQuickhook("hook", "prepare-commit-msg", writeCommitEditMsg(t, "Test"))
+	assert.Error(t, err)
+	assert.Equal(t, "fails: failed\n", output)
+}
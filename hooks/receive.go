@@ -0,0 +1,116 @@
+package hooks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/dirk/quickhook/repo"
+)
+
+const PRE_RECEIVE_HOOK = "pre-receive"
+const UPDATE_HOOK = "update"
+const POST_RECEIVE_HOOK = "post-receive"
+
+// One of the lines Git passes to the pre-receive and post-receive hooks on stdin describing a ref
+// being updated.
+type RefUpdate struct {
+	OldSHA string
+	NewSHA string
+	Ref    string
+}
+
+// Parses the "<old sha> <new sha> <ref>" lines Git passes on stdin.
+func ParseRefUpdates(input string) ([]RefUpdate, error) {
+	updates := []RefUpdate{}
+	scanner := bufio.NewScanner(strings.NewReader(input))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid receive line: %v", line)
+		}
+		updates = append(updates, RefUpdate{
+			OldSHA: fields[0],
+			NewSHA: fields[1],
+			Ref:    fields[2],
+		})
+	}
+	return updates, scanner.Err()
+}
+
+// Runs the server-side pre-receive or post-receive hooks. These work in bare repositories.
+type Receive struct {
+	Repo *repo.Repo
+	// Either PRE_RECEIVE_HOOK or POST_RECEIVE_HOOK.
+	Hook string
+}
+
+// Runs the executables in parallel. They receive the files changed across all of the updated refs
+// on stdin and the path to a file containing the ref updates from Git in
+// QUICKHOOK_RECEIVE_UPDATES.
+func (hook *Receive) Run(stdin io.Reader) error {
+	input, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	updates, err := ParseRefUpdates(string(input))
+	if err != nil {
+		return err
+	}
+
+	executables, err := hook.Repo.FindHookExecutables(hook.Hook)
+	if err != nil {
+		return err
+	}
+	if len(executables) == 0 {
+		return nil
+	}
+
+	files := []string{}
+	for _, update := range updates {
+		changed, err := hook.Repo.FilesChangedInUpdate(update.Ref, update.OldSHA, update.NewSHA)
+		if err != nil {
+			return err
+		}
+		files = append(files, changed...)
+	}
+	files = lo.Uniq(files)
+
+	updatesFile, removeUpdatesFile, err := writeTempFile("quickhook-receive-*", input)
+	if err != nil {
+		return err
+	}
+	defer removeUpdatesFile()
+
+	env := []string{"QUICKHOOK_RECEIVE_UPDATES=" + updatesFile}
+	return runParallel(hook.Repo, hook.Hook, executables, env, strings.Join(files, "\n"))
+}
+
+// Runs the server-side update hooks, which Git runs once for each ref being updated.
+type Update struct {
+	Repo *repo.Repo
+}
+
+// Runs the executables in parallel. They receive the files changed by the update on stdin and the
+// same arguments as Git's update hook.
+func (hook *Update) Run(ref, oldSHA, newSHA string) error {
+	executables, err := hook.Repo.FindHookExecutables(UPDATE_HOOK)
+	if err != nil {
+		return err
+	}
+	if len(executables) == 0 {
+		return nil
+	}
+	files, err := hook.Repo.FilesChangedInUpdate(ref, oldSHA, newSHA)
+	if err != nil {
+		return err
+	}
+	return runParallel(hook.Repo, UPDATE_HOOK, executables, []string{}, strings.Join(files, "\n"), ref, oldSHA, newSHA)
+}
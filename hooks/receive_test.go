@@ -0,0 +1,110 @@
+package hooks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dirk/quickhook/internal/test"
+)
+
+// Returns a bare server repository and a clone of it.
+func initGitForReceive(t *testing.T, hook, script string) (test.TempDir, test.TempDir) {
+	server := test.NewTempDir(t, 1)
+	server.RequireExec("git", "init", "--bare", "--initial-branch=main", "--quiet", ".")
+	server.MkdirAll(".quickhook", hook)
+	server.WriteFile([]string{".quickhook", hook, "checks"}, script)
+	_, err := server.ExecQuickhook("install", "--yes", "--bin="+server.Quickhook)
+	require.NoError(t, err)
+
+	client := test.NewTempDir(t, 1)
+	client.RequireExec("git", "clone", "--quiet", server.Root, ".")
+	client.RequireExec("git", "config", "--local", "user.name", "example")
+	client.RequireExec("git", "config", "--local", "user.email", "example@example.com")
+	client.WriteFile([]string{"example.txt"}, "Changed!")
+	client.RequireExec("git", "add", "example.txt")
+	client.RequireExec("git", "commit", "--quiet", "--message", "Commit example.txt")
+	return server, client
+}
+
+func TestPreReceiveRejectsPush(t *testing.T) {
+	_, client := initGitForReceive(t, "pre-receive",
+		"#!/bin/sh \n if grep forbidden.txt; then exit 1; fi \n cut -d' ' -f3 $QUICKHOOK_RECEIVE_UPDATES 1>&2")
+
+	output, err := client.NewCommand("git", "push", "--quiet", "origin", "main").CombinedOutput()
+	assert.NoError(t, err)
+	assert.Contains(t, string(output), "remote: checks: refs/heads/main")
+
+	client.WriteFile([]string{"forbidden.txt"}, "Not allowed")
+	client.RequireExec("git", "add", "forbidden.txt")
+	client.RequireExec("git", "commit", "--quiet", "--message", "Commit forbidden.txt")
+
+	output, err = client.NewCommand("git", "push", "--quiet", "origin", "main").CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(output), "remote: checks: forbidden.txt")
+	assert.Contains(t, string(output), "pre-receive hook declined")
+}
+
+func TestPreReceiveChecksEveryNewCommit(t *testing.T) {
+	_, client := initGitForReceive(t, "pre-receive",
+		"#!/bin/sh \n if grep forbidden.txt; then exit 1; fi")
+	client.RequireExec("git", "push", "--quiet", "origin", "main")
+
+	// The file isn't in the final tree, but it's still in the history being pushed.
+	client.WriteFile([]string{"forbidden.txt"}, "Not allowed")
+	client.RequireExec("git", "add", "forbidden.txt")
+	client.RequireExec("git", "commit", "--quiet", "--message", "Commit forbidden.txt")
+	client.RequireExec("git", "rm", "--quiet", "forbidden.txt")
+	client.RequireExec("git", "commit", "--quiet", "--message", "Remove forbidden.txt")
+
+	output, err := client.NewCommand("git", "push", "--quiet", "origin", "main").CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(output), "remote: checks: forbidden.txt")
+}
+
+func TestUpdateAfterForcePushOnlyReceivesNewCommitsFiles(t *testing.T) {
+	server, client := initGitForReceive(t, "update",
+		"#!/bin/sh \n echo \"$(cat)\" 1>&2")
+	client.WriteFile([]string{"example.txt"}, "Will be dropped")
+	client.RequireExec("git", "commit", "--quiet", "--all", "--message", "Change example.txt")
+	client.RequireExec("git", "push", "--quiet", "origin", "main")
+	server.RequireExec("git", "config", "receive.denyNonFastForwards", "false")
+
+	client.RequireExec("git", "reset", "--quiet", "--hard", "HEAD~1")
+	client.WriteFile([]string{"kept.txt"}, "Kept")
+	client.RequireExec("git", "add", "kept.txt")
+	client.RequireExec("git", "commit", "--quiet", "--message", "Commit kept.txt")
+
+	output, err := client.NewCommand("git", "push", "--quiet", "--force", "origin", "main").CombinedOutput()
+	assert.NoError(t, err)
+	assert.Contains(t, string(output), "remote: checks: kept.txt")
+	assert.NotContains(t, string(output), "example.txt")
+}
+
+func TestUpdateReceivesRefAndFiles(t *testing.T) {
+	_, client := initGitForReceive(t, "update",
+		"#!/bin/sh \n echo \"$1 $(cat)\" \n exit 1")
+
+	output, err := client.NewCommand("git", "push", "--quiet", "origin", "main").CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(output), "remote: checks: refs/heads/main example.txt")
+}
+
+func TestPostReceiveReceivesFiles(t *testing.T) {
+	_, client := initGitForReceive(t, "post-receive",
+		"#!/bin/sh \n echo \"received $(cat)\" 1>&2")
+
+	output, err := client.NewCommand("git", "push", "--quiet", "origin", "main").CombinedOutput()
+	assert.NoError(t, err)
+	assert.Contains(t, string(output), "remote: checks: received example.txt")
+}
+
+func TestParseRefUpdates(t *testing.T) {
+	updates, err := ParseRefUpdates("12345 67890 refs/heads/main\n")
+	assert.NoError(t, err)
+	assert.Equal(t, []RefUpdate{{"12345", "67890", "refs/heads/main"}}, updates)
+
+	_, err = ParseRefUpdates("12345 refs/heads/main")
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+package hooks
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Executables which are currently running, keyed by pid. The value is true for executables running
+// in their own process group.
+var running = struct {
+	sync.Mutex
+	pids map[int]bool
+	done *sync.Cond
+}{pids: map[int]bool{}}
+
+func init() {
+	running.done = sync.NewCond(&running.Mutex)
+}
+
+// The signal Quickhook was interrupted by, or zero.
+var interrupted atomic.Int32
+
+// Returns the signal Quickhook was interrupted by, or zero if it hasn't been.
+func Interrupted() syscall.Signal {
+	return syscall.Signal(interrupted.Load())
+}
+
+// Forwards the signal to every running executable (see signalExecutable), and stops any more from
+// being started.
+func Interrupt(signal syscall.Signal) {
+	interrupted.Store(int32(signal))
+	signalRunning(signal)
+}
+
+// Waits for the running executables to exit. Any still running after the timeout are killed.
+func WaitForExecutables(timeout time.Duration) {
+	timer := time.AfterFunc(timeout, func() {
+		signalRunning(syscall.SIGKILL)
+	})
+	defer timer.Stop()
+
+	running.Lock()
+	defer running.Unlock()
+	for len(running.pids) > 0 {
+		running.done.Wait()
+	}
+}
+
+func signalRunning(signal syscall.Signal) {
+	running.Lock()
+	defer running.Unlock()
+	for pid, group := range running.pids {
+		signalExecutable(pid, group, signal)
+	}
+}
+
+// Executables in their own process group are signalled along with everything they started. The
+// others are in the terminal's foreground process group, so they've already been sent SIGINT by
+// the terminal (and sending it again would look like a second Ctrl-C); they're only sent SIGTERM,
+// or killed along with their descendants.
+func signalExecutable(pid int, group bool, signal syscall.Signal) {
+	switch {
+	case group:
+		syscall.Kill(-pid, signal)
+	case signal == syscall.SIGKILL:
+		// Find the descendants first, since they're reparented once their parent is killed.
+		descendants := processDescendants(pid)
+		syscall.Kill(pid, signal)
+		for _, descendant := range descendants {
+			syscall.Kill(descendant, signal)
+		}
+	case signal != syscall.SIGINT:
+		syscall.Kill(pid, signal)
+	}
+}
+
+// Returns the pids of the processes started by the process (and by them, and so on), according to
+// ps.
+func processDescendants(pid int) []int {
+	output, err := exec.Command("ps", "-A", "-o", "pid=", "-o", "ppid=").Output()
+	if err != nil {
+		return nil
+	}
+	children := map[int][]int{}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		child, err1 := strconv.Atoi(fields[0])
+		parent, err2 := strconv.Atoi(fields[1])
+		if err1 == nil && err2 == nil {
+			children[parent] = append(children[parent], child)
+		}
+	}
+	descendants := []int{}
+	queue := children[pid]
+	for len(queue) > 0 {
+		descendants = append(descendants, queue[0])
+		queue = append(queue[1:], children[queue[0]]...)
+	}
+	return descendants
+}
+
+// Tracks an executable until the returned function is called. If Quickhook has already been
+// interrupted then it's signalled straight away.
+func trackProcess(pid int, group bool) func() {
+	running.Lock()
+	running.pids[pid] = group
+	running.Unlock()
+	if signal := Interrupted(); signal != 0 {
+		signalExecutable(pid, group, signal)
+	}
+	return func() {
+		running.Lock()
+		delete(running.pids, pid)
+		running.done.Broadcast()
+		running.Unlock()
+	}
+}
@@ -0,0 +1,98 @@
+package hooks
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailedHookRemovesTempDir(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "fails"}, "#!/bin/sh \n exit 1")
+	tmp := t.TempDir()
+
+	cmd := tempDir.NewCommand(tempDir.Quickhook, "hook", "pre-commit")
+	cmd.Env = append(os.Environ(), "TMPDIR="+tmp)
+	err := cmd.Run()
+	assert.Error(t, err)
+	assert.Equal(t, FAILED_EXIT_CODE, err.(*exec.ExitError).ExitCode())
+
+	entries, err := os.ReadDir(tmp)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestInterruptForwardsSignalToHooks(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile(
+		[]string{".quickhook", "pre-commit", "slow"},
+		"#!/bin/sh \n trap 'echo terminated > terminated.txt; exit 1' TERM \n touch started.txt \n while true; do sleep 0.05; done")
+	tmp := t.TempDir()
+
+	cmd := tempDir.NewCommand(tempDir.Quickhook, "hook", "pre-commit")
+	cmd.Env = append(os.Environ(), "TMPDIR="+tmp)
+	require.NoError(t, cmd.Start())
+	waitForFile(t, path.Join(tempDir.Root, "started.txt"))
+	require.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+
+	err := cmd.Wait()
+	assert.Error(t, err)
+	assert.Equal(t, 128+int(syscall.SIGTERM), err.(*exec.ExitError).ExitCode())
+	assert.FileExists(t, path.Join(tempDir.Root, "terminated.txt"))
+	entries, err := os.ReadDir(tmp)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestInterruptFromTerminalOnlyReachesHooksOnce(t *testing.T) {
+	tempDir := initGitForPreCommit(t)
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	// The hook keeps running after SIGINT, and so does the child it starts (since background
+	// processes ignore SIGINT), so both have to be killed. It busy-loops so that the shell runs the
+	// trap as soon as it gets each signal.
+	tempDir.WriteFile(
+		[]string{".quickhook", "pre-commit", "slow"},
+		"#!/bin/sh \n trap 'echo interrupted >> interrupted.txt' INT \n sleep 60 & \n echo $! > child.pid \n while true; do :; done")
+
+	// Run it in a process group of its own, like a shell does, and send SIGINT to the whole group
+	// like the terminal does for Ctrl-C.
+	cmd := tempDir.NewCommand(tempDir.Quickhook, "hook", "pre-commit")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	require.NoError(t, cmd.Start())
+	waitForFile(t, path.Join(tempDir.Root, "child.pid"))
+	start := time.Now()
+	require.NoError(t, syscall.Kill(-cmd.Process.Pid, syscall.SIGINT))
+
+	err := cmd.Wait()
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Error(t, err)
+	assert.Equal(t, 128+int(syscall.SIGINT), err.(*exec.ExitError).ExitCode())
+	data, err := os.ReadFile(path.Join(tempDir.Root, "interrupted.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "interrupted\n", string(data))
+
+	data, err = os.ReadFile(path.Join(tempDir.Root, "child.pid"))
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return syscall.Kill(pid, 0) != nil
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func waitForFile(t *testing.T, name string) {
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(name)
+		return err == nil
+	}, 5*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,86 @@
+package hooks
+
+import (
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/dirk/quickhook/internal"
+	"github.com/dirk/quickhook/repo"
+)
+
+// Runs the executables sequentially with the changed files on stdin. Executables which declare
+// watched paths with "quickhook-watch:" directives are skipped unless one of the files matches.
+func runWatchingExecutables(repo *repo.Repo, hook string, files []string, env []string, arg ...string) error {
+	executables, err := repo.FindHookExecutables(hook)
+	if err != nil {
+		return err
+	}
+	stdin := strings.Join(files, "\n")
+	for _, executable := range executables {
+		directives, err := readExecutableDirectives(repo.Root, executable)
+		if err != nil {
+			return err
+		}
+		patterns := directives.Fields("watch")
+		if len(patterns) > 0 && !anyFileMatches(patterns, files) {
+			continue
+		}
+		result := runExecutable(repo.Root, executable, env, stdin, arg...)
+		if checkResult(result) {
+			return ErrFailed
+		}
+	}
+	return nil
+}
+
+type cachedDirectives struct {
+	modTime    time.Time
+	size       int64
+	directives internal.Directives
+}
+
+var directivesCache sync.Map
+
+// Reads the executable's directives, reusing the ones read earlier in this run (eg. for its watch
+// patterns) unless the file has changed since.
+func readExecutableDirectives(root, executable string) (internal.Directives, error) {
+	name := path.Join(root, executable)
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := directivesCache.Load(name); ok {
+		cached := value.(cachedDirectives)
+		if cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+			return cached.directives, nil
+		}
+	}
+	directives, err := internal.ReadDirectives(name)
+	if err != nil {
+		return nil, err
+	}
+	directivesCache.Store(name, cachedDirectives{info.ModTime(), info.Size(), directives})
+	return directives, nil
+}
+
+// Patterns are matched against the whole path of each file. Patterns without a slash are also
+// matched against just the file's name (so "go.mod" matches "go.mod" and "tools/go.mod").
+func anyFileMatches(patterns []string, files []string) bool {
+	return lo.SomeBy(files, func(file string) bool {
+		return lo.SomeBy(patterns, func(pattern string) bool {
+			if matched, _ := path.Match(pattern, file); matched {
+				return true
+			}
+			if strings.Contains(pattern, "/") {
+				return false
+			}
+			matched, _ := path.Match(pattern, path.Base(file))
+			return matched
+		})
+	})
+}
@@ -2,27 +2,91 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/samber/lo"
 
+	"github.com/dirk/quickhook/hooks"
 	"github.com/dirk/quickhook/repo"
 )
 
-func install(repo *repo.Repo, quickhook string, prompt bool) error {
+// Comment included in every shim so that they can be recognized later (eg. when uninstalling).
+const SHIM_MARKER = "# Generated by Quickhook"
+
+// Suffix added to hooks which existed before Quickhook was installed.
+const LEGACY_SUFFIX = ".quickhook-legacy"
+
+// Matches the shims generated by older versions of Quickhook, eg. "#!/bin/sh\nquickhook hook pre-commit\n".
+var legacyShimRegexp = regexp.MustCompile(`^#!/bin/sh\n[^\n]*quickhook[^\n]* hook [a-z-]+[^\n]*\n$`)
+
+type installOptions struct {
+	// Command to use for Quickhook in the shims.
+	quickhook string
+	prompt    bool
+	// Install into this directory rather than the one Git uses.
+	hooksPath string
+	// Set core.hooksPath to a directory managed by Quickhook and install into that.
+	managedHooksPath bool
+	// Print what would change instead of installing.
+	dryRun bool
+	// Install symlinks to the Quickhook executable (quickhook is its path) instead of shim scripts.
+	symlink bool
+	// Replace core.hooksPath with the managed directory even if it's already set.
+	force bool
+}
+
+// Git config key where the value of core.hooksPath is saved when it's replaced by the managed
+// directory, so that uninstall can restore it.
+const PREVIOUS_HOOKS_PATH_KEY = "quickhook.previousHooksPath"
+
+// Returned by a dry run if installing would change anything.
+var errInstallWouldChange = errors.New("install would change shims")
+
+func install(repo *repo.Repo, options installOptions) error {
 	hooks, err := listHooks(repo)
 	if err != nil {
 		return err
 	}
+	hooksDir, err := resolveHooksDir(repo, options)
+	if err != nil {
+		return err
+	}
+	if options.dryRun {
+		return dryRunInstall(repo, hooksDir, hooks, options)
+	}
+
+	previousHooksPath, err := repo.ConfigGet("core.hooksPath")
+	if err != nil {
+		return err
+	}
+	if options.managedHooksPath && previousHooksPath != hooksDir {
+		// Git will stop running the hooks in the directory it's using now, so bring them along.
+		previousDir, err := repo.HooksDir()
+		if err != nil {
+			return err
+		}
+		err = copyHooks(repo, previousDir, hooksDir)
+		if err != nil {
+			return err
+		}
+	}
 
 	for _, hook := range hooks {
-		shimPath := path.Join(".git", "hooks", hook)
-		if prompt {
+		// Relative to the root if it's inside the repository (so usually .git/hooks/<hook>),
+		// otherwise absolute (eg. for worktrees).
+		shimPath := repo.Rel(path.Join(hooksDir, hook))
+		if options.prompt {
 			shouldInstall, err := promptForInstallShim(os.Stdin, repo, shimPath)
 			if err != nil {
 				return err
@@ -34,14 +98,173 @@ func install(repo *repo.Repo, quickhook string, prompt bool) error {
 			}
 		}
 
-		installShim(repo, shimPath, quickhook, hook, prompt)
+		if options.symlink {
+			linked, err := installSymlink(repo, shimPath, options.quickhook)
+			if err != nil {
+				return err
+			}
+			if linked {
+				fmt.Printf("Installed symlink %v -> %v\n", shimPath, options.quickhook)
+				continue
+			}
+			fmt.Printf("Can't symlink %v since it needs to run the existing hook, using a shim script\n", shimPath)
+		}
+
+		err = installShim(repo, shimPath, options.quickhook, hook)
+		if err != nil {
+			return err
+		}
 
 		fmt.Printf("Installed shim %v\n", shimPath)
 	}
 
+	// Only point Git at the managed directory once the shims are in it.
+	if options.managedHooksPath && previousHooksPath != hooksDir {
+		if previousHooksPath != "" {
+			err = repo.ConfigSet(PREVIOUS_HOOKS_PATH_KEY, previousHooksPath)
+			if err != nil {
+				return err
+			}
+		}
+		err = repo.ConfigSet("core.hooksPath", hooksDir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Set core.hooksPath to %v\n", hooksDir)
+	}
+
+	return nil
+}
+
+// Prints what installing would do without changing anything, with a diff for each shim that would
+// be created or changed. Returns errInstallWouldChange if anything would change.
+func dryRunInstall(repo *repo.Repo, hooksDir string, hooks []string, options installOptions) error {
+	changed := false
+	for _, hook := range hooks {
+		fullPath := path.Join(hooksDir, hook)
+		shimPath := repo.Rel(fullPath)
+		if options.symlink {
+			ok, err := canSymlink(fullPath)
+			if err != nil {
+				return err
+			}
+			if ok {
+				if target, err := os.Readlink(fullPath); err == nil && target == options.quickhook {
+					fmt.Printf("Unchanged %v\n", shimPath)
+				} else {
+					fmt.Printf("Would link %v to %v\n", shimPath, options.quickhook)
+					changed = true
+				}
+				continue
+			}
+		}
+		content, err := shimContent(options.quickhook, hook)
+		if err != nil {
+			return err
+		}
+
+		existing, err := os.ReadFile(fullPath)
+		exists := true
+		if os.IsNotExist(err) {
+			exists = false
+		} else if err != nil {
+			return err
+		}
+		if exists && string(existing) == content {
+			fmt.Printf("Unchanged %v\n", shimPath)
+			continue
+		}
+
+		changed = true
+		fromFile := shimPath
+		if !exists {
+			fmt.Printf("Would create %v\n", shimPath)
+			fromFile = "/dev/null"
+		} else if isShim, _ := isQuickhookShim(fullPath); isShim {
+			fmt.Printf("Would overwrite %v\n", shimPath)
+		} else {
+			fmt.Printf("Would move existing hook %v to %v and create shim\n",
+				shimPath, path.Base(shimPath)+LEGACY_SUFFIX)
+		}
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(existing)),
+			B:        difflib.SplitLines(content),
+			FromFile: fromFile,
+			ToFile:   shimPath,
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Print(diff)
+	}
+
+	if options.managedHooksPath {
+		hooksPath, err := repo.ConfigGet("core.hooksPath")
+		if err != nil {
+			return err
+		}
+		if hooksPath != hooksDir {
+			fmt.Printf("Would set core.hooksPath to %v\n", hooksDir)
+			changed = true
+		}
+	}
+
+	if changed {
+		return errInstallWouldChange
+	}
 	return nil
 }
 
+// Figures out which directory to install shims into, warning if it might not be the one the user
+// expects.
+func resolveHooksDir(repo *repo.Repo, options installOptions) (string, error) {
+	if options.managedHooksPath {
+		quickhookDir, err := repo.QuickhookDir()
+		if err != nil {
+			return "", err
+		}
+		hooksDir := path.Join(quickhookDir, "hooks")
+		hooksPath, err := repo.ConfigGet("core.hooksPath")
+		if err != nil {
+			return "", err
+		}
+		if hooksPath != "" && hooksPath != hooksDir && !options.force {
+			return "", fmt.Errorf(
+				"core.hooksPath is already set to %v, pass --force to replace it (uninstall will restore it)", hooksPath)
+		}
+		return hooksDir, nil
+	}
+
+	gitHooksDir, err := repo.HooksDir()
+	if err != nil {
+		return "", err
+	}
+	if options.hooksPath != "" {
+		hooksDir, err := filepath.Abs(options.hooksPath)
+		if err != nil {
+			return "", err
+		}
+		if hooksDir != gitHooksDir {
+			fmt.Fprintf(os.Stderr,
+				"Warning: Git is using hooks from %v, so it won't run shims in %v unless core.hooksPath is set to it\n",
+				gitHooksDir, hooksDir)
+		}
+		return hooksDir, nil
+	}
+
+	hooksPath, err := repo.ConfigGet("core.hooksPath")
+	if err != nil {
+		return "", err
+	}
+	if hooksPath != "" {
+		fmt.Fprintf(os.Stderr,
+			"Warning: core.hooksPath is set, so installing shims into %v (it may be shared with other repositories or tools)\n",
+			gitHooksDir)
+	}
+	return gitHooksDir, nil
+}
+
 func listHooks(repo *repo.Repo) ([]string, error) {
 	hooksPath := path.Join(repo.Root, ".quickhook")
 
@@ -49,30 +272,28 @@ func listHooks(repo *repo.Repo) ([]string, error) {
 	if err != nil {
 		if os.IsNotExist(err) {
 			fmt.Fprintf(os.Stderr, "Missing hooks directory: %v\n", hooksPath)
-			os.Exit(66) // EX_NOINPUT
+			exit(66) // EX_NOINPUT
 		} else {
 			return nil, err
 		}
 	}
 
-	var hooks []string
+	var names []string
 	for _, entry := range entries {
 		name := entry.Name()
 		// Rename the mutating hook to the regular pre-commit one.
 		if name == "pre-commit-mutating" {
 			name = "pre-commit"
 		}
-		isHook := name == "pre-commit" ||
-			name == "commit-msg"
-		if entry.IsDir() && isHook {
-			hooks = append(hooks, name)
+		if entry.IsDir() && lo.Contains(hooks.GIT_HOOKS, name) {
+			names = append(names, name)
 		}
 	}
-	return lo.Uniq(hooks), nil
+	return lo.Uniq(names), nil
 }
 
 func promptForInstallShim(stdin io.Reader, repo *repo.Repo, shimPath string) (bool, error) {
-	_, err := os.Stat(path.Join(repo.Root, shimPath))
+	_, err := os.Stat(repo.Abs(shimPath))
 	exists := true
 	if os.IsNotExist(err) {
 		exists = false
@@ -83,10 +304,17 @@ func promptForInstallShim(stdin io.Reader, repo *repo.Repo, shimPath string) (bo
 	var message string
 	if exists {
 		message = fmt.Sprintf("Overwrite existing file %v?", shimPath)
+		if isShim, err := isQuickhookShim(repo.Abs(shimPath)); err == nil && !isShim {
+			message = fmt.Sprintf("Overwrite existing file %v? (It will be moved to %v)",
+				shimPath, path.Base(shimPath)+LEGACY_SUFFIX)
+		}
 	} else {
 		message = fmt.Sprintf("Create file %v?", shimPath)
 	}
+	return promptYesNo(stdin, message)
+}
 
+func promptYesNo(stdin io.Reader, message string) (bool, error) {
 	scanner := bufio.NewScanner(stdin)
 	for {
 		fmt.Printf("%v [yn] ", message)
@@ -110,44 +338,281 @@ func promptForInstallShim(stdin io.Reader, repo *repo.Repo, shimPath string) (bo
 	}
 }
 
-func installShim(repo *repo.Repo, shimPath, quickhook, hook string, prompt bool) error {
-	command, err := shimCommandForHook(quickhook, hook)
+func installShim(repo *repo.Repo, shimPath, quickhook, hook string) error {
+	content, err := shimContent(quickhook, hook)
 	if err != nil {
 		return err
 	}
 
-	lines := []string{
-		"#!/bin/sh",
-		command,
-		"", // So we get a trailing newline when we join
+	fullPath := repo.Abs(shimPath)
+	// The hooks directory may not exist (eg. if Git was initialized with an empty template).
+	err = os.MkdirAll(path.Dir(fullPath), 0755)
+	if err != nil {
+		return err
 	}
 
-	file, err := os.Create(shimPath)
+	err = moveAsideLegacyHook(fullPath)
 	if err != nil {
 		return err
 	}
+	return writeShimFile(fullPath, content)
+}
+
+func writeShimFile(fullPath, content string) error {
+	// Write to a temporary file and rename it into place: the shim may be replaced while it's
+	// running (when shims are regenerated by checkShims), and the shell reads it as it goes.
+	file, err := os.CreateTemp(path.Dir(fullPath), path.Base(fullPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
 	defer file.Close()
 
-	err = os.Chmod(shimPath, 0755)
+	err = file.Chmod(0755)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(content)
 	if err != nil {
 		return err
 	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), fullPath)
+}
+
+// Generates the shim script for the hook. If there's a legacy hook (one which existed before
+// Quickhook was installed) next to the shim then the shim runs it first, with the same arguments
+// and stdin, and fails if either it or Quickhook fails.
+func shimContent(quickhook, hook string) (string, error) {
+	command, err := shimCommandForHook(quickhook, hook)
+	if err != nil {
+		return "", err
+	}
+
+	lines := shimHeader(quickhook, "quickhook install")
+	lines = append(lines, shimMissingCheck(quickhook)...)
+	lines = append(lines, []string{
+		"if [ -x \"$0" + LEGACY_SUFFIX + "\" ]; then",
+		"\tinput=$(mktemp) || exit 1",
+		"\ttrap 'rm -f \"$input\"' EXIT",
+		"\tif [ ! -t 0 ]; then cat > \"$input\"; fi",
+		"\t\"$0" + LEGACY_SUFFIX + "\" \"$@\" < \"$input\"",
+		"\tlegacy=$?",
+		"\t" + command + " < \"$input\"",
+		"\tstatus=$?",
+		"\tif [ $legacy -ne 0 ]; then exit $legacy; fi",
+		"\texit $status",
+		"fi",
+		"exec " + command,
+		"", // So we get a trailing newline when we join
+	}...)
+	return strings.Join(lines, "\n"), nil
+}
 
-	file.WriteString(strings.Join(lines, "\n"))
+// The first lines of every shim: the marker, directives recording what generated it, and the
+// environment variables for checkShims.
+func shimHeader(quickhook, installCommand string) []string {
+	return []string{
+		"#!/bin/sh",
+		SHIM_MARKER + ", do not edit. Run `" + installCommand + "` to update.",
+		"# quickhook-version: " + VERSION,
+		"# quickhook-shim-format: " + strconv.Itoa(SHIM_FORMAT),
+		"# quickhook-bin: " + quickhook,
+		"export " + SHIM_PATH_ENV + "=\"$0\" " + SHIM_STAMP_ENV + "=" + shellQuote(shimStamp()),
+	}
+}
+
+// Lines for the shim which check that the Quickhook command exists, so that Git doesn't just fail
+// with "command not found". What happens then is up to the quickhook.onMissing config: by default
+// the hook fails, but with "skip" it only warns (and still runs any legacy hook).
+func shimMissingCheck(quickhook string) []string {
+	executable := shellFirstWord(quickhook)
+	message := fmt.Sprintf(
+		"Quickhook couldn't find %v, see https://github.com/dirk/quickhook#installation to install it", executable)
+	return []string{
+		"if ! command -v " + shellQuote(executable) + " > /dev/null 2>&1; then",
+		"\techo " + shellQuote(message) + " >&2",
+		"\tif [ \"$(git config quickhook.onMissing)\" != skip ]; then",
+		"\t\techo 'Run `git config quickhook.onMissing skip` to skip these hooks when Quickhook is missing' >&2",
+		"\t\texit 1",
+		"\tfi",
+		"\techo 'Skipping hooks since quickhook.onMissing is skip' >&2",
+		"\tif [ -x \"$0" + LEGACY_SUFFIX + "\" ]; then exec \"$0" + LEGACY_SUFFIX + "\" \"$@\"; fi",
+		"\texit 0",
+		"fi",
+	}
+}
 
+// Quotes the string for use as a single word in a shell script.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// Checks that the command the shims will use to run Quickhook can be found. Shims run in the root
+// of the repository, so relative paths are resolved against root (if it's given).
+func checkQuickhookCommand(root, quickhook string) error {
+	executable := shellFirstWord(quickhook)
+	if executable == "" {
+		return errors.New("empty command")
+	}
+	if root != "" && strings.Contains(executable, "/") && !path.IsAbs(executable) {
+		executable = path.Join(root, executable)
+	}
+	_, err := exec.LookPath(executable)
+	return err
+}
+
+// Returns the first word of a shell command (the executable), without any quotes around it, eg.
+// "/path/to/my quickhook" for "'/path/to/my quickhook' hook".
+func shellFirstWord(command string) string {
+	command = strings.TrimSpace(command)
+	if command != "" && (command[0] == '\'' || command[0] == '"') {
+		if end := strings.IndexByte(command[1:], command[0]); end >= 0 {
+			return command[1 : end+1]
+		}
+	}
+	if fields := strings.Fields(command); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// If there's an existing hook which wasn't generated by Quickhook then move it to the legacy path
+// so that it can be restored when uninstalling.
+// Copies the hooks (other than Quickhook's shims) from one directory to another, leaving any which
+// are already in the other directory alone. Symlinks are copied as links to their resolved target.
+// Hooks which get a shim are then moved aside and chained like any other legacy hook.
+func copyHooks(repo *repo.Repo, fromDir, toDir string) error {
+	entries, err := os.ReadDir(fromDir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	err = os.MkdirAll(toDir, 0755)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !lo.Contains(hooks.GIT_HOOKS, entry.Name()) {
+			continue
+		}
+		fromPath := path.Join(fromDir, entry.Name())
+		toPath := path.Join(toDir, entry.Name())
+		if isShim, err := isQuickhookShim(fromPath); err != nil || isShim {
+			continue
+		}
+		if _, err := os.Lstat(toPath); err == nil {
+			continue
+		}
+		err = copyHook(fromPath, toPath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Copied existing hook %v to %v\n", repo.Rel(fromPath), repo.Rel(toPath))
+	}
+	return nil
+}
+
+func copyHook(fromPath, toPath string) error {
+	target, err := filepath.EvalSymlinks(fromPath)
+	if err != nil {
+		return err
+	}
+	if target != fromPath {
+		target, err = filepath.Abs(target)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, toPath)
+	}
+	info, err := os.Stat(fromPath)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return nil
+	}
+	data, err := os.ReadFile(fromPath)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(toPath, data, info.Mode().Perm())
+}
+
+func moveAsideLegacyHook(fullPath string) error {
+	isShim, err := isQuickhookShim(fullPath)
+	if os.IsNotExist(err) || (err == nil && isShim) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	legacyPath := fullPath + LEGACY_SUFFIX
+	if _, err := os.Lstat(legacyPath); err == nil {
+		return fmt.Errorf("can't move existing hook %v since %v already exists", fullPath, legacyPath)
+	}
+	err = os.Rename(fullPath, legacyPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Moved existing hook to %v\n", path.Base(legacyPath))
 	return nil
 }
 
+// Returns true if the file is a shim generated by Quickhook (or a symlink installed by it).
+func isQuickhookShim(fullPath string) (bool, error) {
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return false, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return isSymlinkShim(fullPath)
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return false, err
+	}
+	content := string(data)
+	if strings.Contains(content, SHIM_MARKER) {
+		return true, nil
+	}
+	// Shims from older versions didn't have the marker.
+	return legacyShimRegexp.MatchString(content), nil
+}
+
 func shimCommandForHook(quickhook, hook string) (string, error) {
 	var args string
 
 	switch hook {
 	case "pre-commit":
 		args = "pre-commit"
+	case "prepare-commit-msg":
+		args = "prepare-commit-msg \"$@\""
 	case "commit-msg":
 		args = "commit-msg $1"
+	case "post-commit":
+		args = "post-commit"
+	case "post-checkout":
+		args = "post-checkout \"$1\" \"$2\" \"$3\""
+	case "post-merge":
+		args = "post-merge \"$1\""
+	case "pre-receive":
+		args = "pre-receive"
+	case "update":
+		args = "update \"$1\" \"$2\" \"$3\""
+	case "post-receive":
+		args = "post-receive"
+	case "pre-push":
+		args = "pre-push \"$1\" \"$2\""
 	default:
-		return "", fmt.Errorf("invalid hook: %v", hook)
+		if !lo.Contains(hooks.GIT_HOOKS, hook) {
+			return "", fmt.Errorf("invalid hook: %v", hook)
+		}
+		args = fmt.Sprintf("run %s -- \"$@\"", hook)
 	}
 
 	return fmt.Sprintf("%s hook %s", quickhook, args), nil
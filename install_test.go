@@ -2,11 +2,14 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"path"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/dirk/quickhook/internal/test"
 	"github.com/dirk/quickhook/repo"
@@ -95,3 +98,303 @@ func TestPromptForInstall(t *testing.T) {
 		})
 	}
 }
+
+func TestInstallShimCommands(t *testing.T) {
+	shimTests := []struct {
+		hook    string
+		command string
+	}{
+		{"pre-commit", "quickhook hook pre-commit"},
+		{"pre-push", "quickhook hook pre-push \"$1\" \"$2\""},
+		{"pre-rebase", "quickhook hook run pre-rebase -- \"$@\""},
+	}
+	for _, tt := range shimTests {
+		t.Run(tt.hook, func(t *testing.T) {
+			command, err := shimCommandForHook("quickhook", tt.hook)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.command, command)
+		})
+	}
+
+	_, err := shimCommandForHook("quickhook", "pre-everything")
+	assert.Error(t, err)
+}
+
+func TestInstallIgnoresUnknownHooks(t *testing.T) {
+	tempDir := test.NewTempDir(t, 0)
+	tempDir.RequireExec("git", "init", "--quiet", ".")
+	tempDir.MkdirAll(".quickhook", "pre-rebase")
+	tempDir.MkdirAll(".quickhook", "pre-everything")
+
+	output, err := tempDir.ExecQuickhook("install", "--yes")
+	assert.NoError(t, err)
+	assert.Equal(t, "Installed shim .git/hooks/pre-rebase", strings.TrimSpace(output))
+}
+
+func initGitForInstall(t *testing.T) test.TempDir {
+	tempDir := test.NewTempDir(t, 0)
+	tempDir.RequireExec("git", "init", "--initial-branch=main", "--quiet", ".")
+	tempDir.RequireExec("git", "config", "--local", "user.name", "example")
+	tempDir.RequireExec("git", "config", "--local", "user.email", "example@example.com")
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "passes"}, "#!/bin/sh \n echo \"passed\"")
+	tempDir.RequireExec("git", "add", ".quickhook")
+	tempDir.RequireExec("git", "commit", "--quiet", "--no-verify", "--message", "Add hooks")
+	return tempDir
+}
+
+func execQuickhookIn(tempDir test.TempDir, dir string, arg ...string) (string, error) {
+	cmd := tempDir.NewCommand(tempDir.Quickhook, arg...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+func TestInstallFromSubdirectory(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.MkdirAll("sub", "directory")
+
+	output, err := execQuickhookIn(tempDir, path.Join(tempDir.Root, "sub", "directory"), "install", "--yes")
+	assert.NoError(t, err)
+	assert.Equal(t, "Installed shim .git/hooks/pre-commit", strings.TrimSpace(output))
+	assert.FileExists(t, path.Join(tempDir.Root, ".git", "hooks", "pre-commit"))
+	assert.NoDirExists(t, path.Join(tempDir.Root, "sub", "directory", ".git"))
+}
+
+func TestInstallFromWorktree(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	worktree := path.Join(t.TempDir(), "worktree")
+	tempDir.RequireExec("git", "worktree", "add", "--quiet", worktree)
+
+	output, err := execQuickhookIn(tempDir, worktree, "install", "--yes")
+	assert.NoError(t, err)
+	// Worktrees share the main repository's hooks.
+	shimPath, err := filepath.EvalSymlinks(path.Join(tempDir.Root, ".git", "hooks"))
+	require.NoError(t, err)
+	shimPath = path.Join(shimPath, "pre-commit")
+	assert.Equal(t, fmt.Sprintf("Installed shim %v", shimPath), strings.TrimSpace(output))
+	assert.FileExists(t, shimPath)
+}
+
+func TestInstallFromSubmodule(t *testing.T) {
+	submodule := initGitForInstall(t)
+	tempDir := test.NewTempDir(t, 0)
+	tempDir.RequireExec("git", "init", "--quiet", ".")
+	tempDir.RequireExec("git", "-c", "protocol.file.allow=always", "submodule", "--quiet", "add", submodule.Root, "sub")
+
+	output, err := execQuickhookIn(tempDir, path.Join(tempDir.Root, "sub"), "install", "--yes")
+	assert.NoError(t, err)
+	shimPath, err := filepath.EvalSymlinks(path.Join(tempDir.Root, ".git", "modules", "sub", "hooks"))
+	require.NoError(t, err)
+	shimPath = path.Join(shimPath, "pre-commit")
+	assert.Equal(t, fmt.Sprintf("Installed shim %v", shimPath), strings.TrimSpace(output))
+	assert.FileExists(t, shimPath)
+}
+
+func TestInstallIntoCoreHooksPath(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.RequireExec("git", "config", "--local", "core.hooksPath", "shared-hooks")
+
+	output, err := tempDir.ExecQuickhook("install", "--yes")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "Warning: core.hooksPath is set")
+	assert.Contains(t, output, "Installed shim shared-hooks/pre-commit")
+	assert.FileExists(t, path.Join(tempDir.Root, "shared-hooks", "pre-commit"))
+}
+
+func TestInstallIntoHooksPathFlag(t *testing.T) {
+	tempDir := initGitForInstall(t)
+
+	output, err := tempDir.ExecQuickhook("install", "--yes", "--hooks-path=other-hooks")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "so it won't run shims in")
+	assert.Contains(t, output, "Installed shim other-hooks/pre-commit")
+	assert.FileExists(t, path.Join(tempDir.Root, "other-hooks", "pre-commit"))
+}
+
+func TestInstallWithManagedHooksPath(t *testing.T) {
+	tempDir := initGitForInstall(t)
+
+	output, err := tempDir.ExecQuickhook("install", "--yes", "--managed-hooks-path")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "Installed shim .git/quickhook/hooks/pre-commit")
+	assert.FileExists(t, path.Join(tempDir.Root, ".git", "quickhook", "hooks", "pre-commit"))
+
+	hooksPath, err := tempDir.NewCommand("git", "rev-parse", "--git-path", "hooks").Output()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(string(hooksPath)), "/.git/quickhook/hooks"))
+}
+
+func TestInstallWithManagedHooksPathKeepsExistingHooks(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.MkdirAll(".git", "hooks")
+	tempDir.WriteFile([]string{".git", "hooks", "pre-commit"}, "#!/bin/sh \n echo \"previous\"")
+	tempDir.WriteFile([]string{".git", "hooks", "pre-push"}, "#!/bin/sh \n echo \"lfs\"")
+
+	output, err := tempDir.ExecQuickhook("install", "--yes", "--managed-hooks-path")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "Copied existing hook .git/hooks/pre-commit to .git/quickhook/hooks/pre-commit\n")
+	assert.Contains(t, output, "Copied existing hook .git/hooks/pre-push to .git/quickhook/hooks/pre-push\n")
+	assert.Contains(t, output, "Moved existing hook to pre-commit.quickhook-legacy\n")
+	legacy, err := os.ReadFile(path.Join(tempDir.Root, ".git", "quickhook", "hooks", "pre-commit.quickhook-legacy"))
+	require.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh \n echo \"previous\"", string(legacy))
+	assert.FileExists(t, path.Join(tempDir.Root, ".git", "quickhook", "hooks", "pre-push"))
+}
+
+func TestInstallWithManagedHooksPathRequiresForceToReplaceHooksPath(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.RequireExec("git", "config", "--local", "core.hooksPath", "shared-hooks")
+
+	output, err := tempDir.ExecQuickhook("install", "--yes", "--managed-hooks-path")
+	assert.Error(t, err)
+	assert.Contains(t, output, "core.hooksPath is already set to shared-hooks, pass --force to replace it")
+	hooksPath, err := tempDir.NewCommand("git", "config", "--get", "core.hooksPath").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "shared-hooks\n", string(hooksPath))
+
+	tempDir.MkdirAll("shared-hooks")
+	tempDir.WriteFile([]string{"shared-hooks", "pre-push"}, "#!/bin/sh \n echo \"lfs\"")
+	output, err = tempDir.ExecQuickhook("install", "--yes", "--managed-hooks-path", "--force")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "Copied existing hook shared-hooks/pre-push to .git/quickhook/hooks/pre-push\n")
+	previous, err := tempDir.NewCommand("git", "config", "--get", "quickhook.previousHooksPath").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "shared-hooks\n", string(previous))
+	assert.FileExists(t, path.Join(tempDir.Root, "shared-hooks", "pre-push"))
+}
+
+func TestInstallChainsLegacyHook(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.MkdirAll(".git", "hooks")
+	tempDir.WriteFile([]string{".git", "hooks", "pre-commit"}, "#!/bin/sh \n echo \"legacy failed\" \n exit 1")
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--bin="+tempDir.Quickhook)
+	require.NoError(t, err)
+
+	tempDir.WriteFile([]string{"example.txt"}, "Changed!")
+	tempDir.RequireExec("git", "add", "example.txt")
+	output, err := tempDir.NewCommand("git", "commit", "--quiet", "--message", "Commit example.txt").CombinedOutput()
+	assert.Error(t, err)
+	assert.Equal(t, "legacy failed\n", string(output))
+
+	tempDir.WriteFile([]string{".git", "hooks", "pre-commit.quickhook-legacy"}, "#!/bin/sh \n echo \"legacy passed\"")
+	output, err = tempDir.NewCommand("git", "commit", "--quiet", "--message", "Commit example.txt").CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy passed\n", string(output))
+}
+
+func TestInstallChainsLegacyHookWithStdin(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.MkdirAll(".git", "hooks")
+	tempDir.WriteFile([]string{".git", "hooks", "post-rewrite"}, "#!/bin/sh \n echo \"legacy $1 $(cat)\" 1>&2")
+	tempDir.MkdirAll(".quickhook", "post-rewrite")
+	tempDir.WriteFile([]string{".quickhook", "post-rewrite", "rewritten"}, "#!/bin/sh \n echo \"quickhook $1 $(cat)\" 1>&2")
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--bin="+tempDir.Quickhook)
+	require.NoError(t, err)
+
+	before, err := tempDir.NewCommand("git", "rev-parse", "HEAD").Output()
+	require.NoError(t, err)
+	output, err := tempDir.NewCommand(
+		"git", "commit", "--quiet", "--no-verify", "--amend", "--message", "Amended").CombinedOutput()
+	assert.NoError(t, err)
+	after, err := tempDir.NewCommand("git", "rev-parse", "HEAD").Output()
+	require.NoError(t, err)
+
+	rewrite := strings.TrimSpace(string(before)) + " " + strings.TrimSpace(string(after))
+	assert.Equal(t,
+		fmt.Sprintf("legacy amend %s\nrewritten: quickhook amend %s\n", rewrite, rewrite),
+		string(output))
+}
+
+func TestInstallDryRun(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.MkdirAll(".quickhook", "commit-msg")
+
+	output, err := tempDir.ExecQuickhook("install", "--dry-run")
+	assert.Error(t, err)
+	assert.Contains(t, output, "Would create .git/hooks/commit-msg\n--- /dev/null\n+++ .git/hooks/commit-msg\n")
+	assert.Contains(t, output, "+exec quickhook hook commit-msg $1\n")
+	assert.Contains(t, output, "Would create .git/hooks/pre-commit\n")
+	assert.NoFileExists(t, path.Join(tempDir.Root, ".git", "hooks", "pre-commit"))
+
+	_, err = tempDir.ExecQuickhook("install", "--yes")
+	require.NoError(t, err)
+	output, err = tempDir.ExecQuickhook("install", "--dry-run")
+	assert.NoError(t, err)
+	assert.Equal(t, "Unchanged .git/hooks/commit-msg\nUnchanged .git/hooks/pre-commit\n", output)
+
+	output, err = tempDir.ExecQuickhook("install", "--dry-run", "--bin=other-quickhook")
+	assert.Error(t, err)
+	assert.Contains(t, output, "Would overwrite .git/hooks/pre-commit\n")
+	assert.Contains(t, output, "-exec quickhook hook pre-commit\n+exec other-quickhook hook pre-commit\n")
+}
+
+func TestInstallBinMustExist(t *testing.T) {
+	tempDir := initGitForInstall(t)
+
+	output, err := tempDir.ExecQuickhook("install", "--yes", "--bin=missing-quickhook")
+	assert.Error(t, err)
+	assert.Contains(t, output, "--bin missing-quickhook can't be found")
+	assert.NoFileExists(t, path.Join(tempDir.Root, ".git", "hooks", "pre-commit"))
+}
+
+func TestInstallBinDryRunWarns(t *testing.T) {
+	tempDir := initGitForInstall(t)
+
+	output, err := tempDir.ExecQuickhook("install", "--dry-run", "--bin=missing-quickhook")
+	assert.Error(t, err)
+	assert.Contains(t, output, "Warning: --bin missing-quickhook can't be found")
+	assert.Contains(t, output, "Would create .git/hooks/pre-commit\n")
+}
+
+func TestInstallBinRelativeToRoot(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.MkdirAll("my tools")
+	tempDir.WriteFile([]string{"my tools", "quickhook"}, "#!/bin/sh \n exec "+tempDir.Quickhook+" \"$@\"")
+	tempDir.MkdirAll("subdirectory")
+
+	cmd := tempDir.NewCommand(tempDir.Quickhook, "install", "--yes", "--bin='./my tools/quickhook'")
+	cmd.Dir = path.Join(tempDir.Root, "subdirectory")
+	output, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(output))
+
+	tempDir.WriteFile([]string{"example.txt"}, "Changed!")
+	tempDir.RequireExec("git", "add", "example.txt")
+	// The shim would fail if it couldn't find the command.
+	commitOutput, err := tempDir.NewCommand("git", "commit", "--quiet", "--message", "Commit example.txt").CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(commitOutput))
+}
+
+func TestShellFirstWord(t *testing.T) {
+	assert.Equal(t, "quickhook", shellFirstWord("quickhook"))
+	assert.Equal(t, "go", shellFirstWord(" go tool quickhook"))
+	assert.Equal(t, "/my tools/quickhook", shellFirstWord("'/my tools/quickhook' --flag"))
+	assert.Equal(t, "/my tools/quickhook", shellFirstWord("\"/my tools/quickhook\""))
+	assert.Equal(t, "", shellFirstWord(""))
+}
+
+func TestShimWithMissingQuickhook(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	content, err := shimContent("missing-quickhook", "pre-commit")
+	require.NoError(t, err)
+	tempDir.MkdirAll(".git", "hooks")
+	tempDir.WriteFile([]string{".git", "hooks", "pre-commit"}, content)
+
+	tempDir.WriteFile([]string{"example.txt"}, "Changed!")
+	tempDir.RequireExec("git", "add", "example.txt")
+	output, err := tempDir.NewCommand("git", "commit", "--quiet", "--message", "Commit example.txt").CombinedOutput()
+	assert.Error(t, err)
+	assert.Equal(t,
+		"Quickhook couldn't find missing-quickhook, see https://github.com/dirk/quickhook#installation to install it\n"+
+			"Run `git config quickhook.onMissing skip` to skip these hooks when Quickhook is missing\n",
+		string(output))
+
+	tempDir.RequireExec("git", "config", "--local", "quickhook.onMissing", "skip")
+	output, err = tempDir.NewCommand("git", "commit", "--quiet", "--message", "Commit example.txt").CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"Quickhook couldn't find missing-quickhook, see https://github.com/dirk/quickhook#installation to install it\n"+
+			"Skipping hooks since quickhook.onMissing is skip\n",
+		string(output))
+}
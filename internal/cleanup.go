@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"sort"
+	"sync"
+)
+
+var cleanups = struct {
+	sync.Mutex
+	next      int
+	functions map[int]func()
+}{functions: map[int]func(){}}
+
+// Registers a function (eg. to remove a temporary file) to be run by RunCleanups when Quickhook
+// exits, so that it still happens if Quickhook is interrupted. Returns a function which runs it
+// straight away instead and unregisters it, which is meant to be deferred:
+//
+//	defer internal.AddCleanup(func() { os.RemoveAll(dir) })()
+func AddCleanup(function func()) func() {
+	cleanups.Lock()
+	defer cleanups.Unlock()
+	id := cleanups.next
+	cleanups.next += 1
+	cleanups.functions[id] = function
+	return func() {
+		cleanups.Lock()
+		function, ok := cleanups.functions[id]
+		delete(cleanups.functions, id)
+		cleanups.Unlock()
+		if ok {
+			function()
+		}
+	}
+}
+
+// Runs every registered cleanup which hasn't run yet, most recently added first.
+func RunCleanups() {
+	cleanups.Lock()
+	ids := []int{}
+	for id := range cleanups.functions {
+		ids = append(ids, id)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+	functions := []func(){}
+	for _, id := range ids {
+		functions = append(functions, cleanups.functions[id])
+		delete(cleanups.functions, id)
+	}
+	cleanups.Unlock()
+
+	for _, function := range functions {
+		function()
+	}
+}
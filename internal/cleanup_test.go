@@ -0,0 +1,23 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanups(t *testing.T) {
+	ran := []string{}
+	AddCleanup(func() { ran = append(ran, "first") })
+	early := AddCleanup(func() { ran = append(ran, "early") })
+	AddCleanup(func() { ran = append(ran, "last") })
+
+	early()
+	early()
+	assert.Equal(t, []string{"early"}, ran)
+
+	RunCleanups()
+	assert.Equal(t, []string{"early", "last", "first"}, ran)
+	RunCleanups()
+	assert.Equal(t, []string{"early", "last", "first"}, ran)
+}
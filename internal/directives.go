@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// How much of the start of a file to search for directives.
+const DIRECTIVES_HEADER_SIZE = 4096
+
+// Matches comment lines like "# quickhook-watch: go.mod go.sum". Supports the comment styles of
+// most scripting languages.
+var directiveRegexp = regexp.MustCompile(`^\s*(?:#|//|--|;)\s*quickhook-([a-z-]+):\s*(.*?)\s*$`)
+
+// Directives are comments in the header of a file which Quickhook reads to configure how it
+// treats that file. Each key maps to the values of every line with that key, in order.
+type Directives map[string][]string
+
+// Returns the value of the last directive with the key, or an empty string if there isn't one.
+func (directives Directives) Get(key string) string {
+	values := directives[key]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// Returns the whitespace-separated fields of every directive with the key.
+func (directives Directives) Fields(key string) []string {
+	fields := []string{}
+	for _, value := range directives[key] {
+		fields = append(fields, strings.Fields(value)...)
+	}
+	return fields
+}
+
+func ParseDirectives(reader io.Reader) (Directives, error) {
+	header, err := io.ReadAll(io.LimitReader(reader, DIRECTIVES_HEADER_SIZE))
+	if err != nil {
+		return nil, err
+	}
+	directives := Directives{}
+	scanner := bufio.NewScanner(bytes.NewReader(header))
+	for scanner.Scan() {
+		match := directiveRegexp.FindStringSubmatch(scanner.Text())
+		if match != nil {
+			directives[match[1]] = append(directives[match[1]], match[2])
+		}
+	}
+	return directives, scanner.Err()
+}
+
+func ReadDirectives(name string) (Directives, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseDirectives(file)
+}
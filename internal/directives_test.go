@@ -0,0 +1,24 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDirectives(t *testing.T) {
+	directives, err := ParseDirectives(strings.NewReader(strings.Join([]string{
+		"#!/bin/sh",
+		"# quickhook-watch: go.mod go.sum",
+		"// quickhook-watch:   *.proto  ",
+		"#quickhook-timeout: 30s",
+		"echo \"# quickhook-timeout: 1s\"",
+		"go mod download",
+	}, "\n")))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"go.mod", "go.sum", "*.proto"}, directives.Fields("watch"))
+	assert.Equal(t, "30s", directives.Get("timeout"))
+	assert.Equal(t, "", directives.Get("missing"))
+	assert.Empty(t, directives.Fields("missing"))
+}
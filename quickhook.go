@@ -1,14 +1,23 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/fatih/color"
+	"github.com/samber/lo"
 
 	"github.com/dirk/quickhook/hooks"
+	"github.com/dirk/quickhook/internal"
 	"github.com/dirk/quickhook/repo"
 	"github.com/dirk/quickhook/tracing"
 )
@@ -17,32 +26,103 @@ const VERSION = "main"
 
 var cli struct {
 	Install struct {
-		Yes bool   `short:"y" help:"Assume yes for all prompts"`
-		Bin string `help:"Path to Quickhook executable to use in the shim (if it's not on $PATH)"`
-	} `cmd:"" help:"Install Quickhook shims into .git/hooks"`
+		Yes              bool   `short:"y" help:"Assume yes for all prompts"`
+		Bin              string `help:"Path to Quickhook executable to use in the shim (if it's not on $PATH)"`
+		HooksPath        string `xor:"hooks-path" help:"Install shims into this directory instead of the one Git uses"`
+		ManagedHooksPath bool   `xor:"hooks-path" help:"Set core.hooksPath to a directory managed by Quickhook and install shims there"`
+		Force            bool   `help:"With --managed-hooks-path, replace core.hooksPath even if it's already set"`
+		DryRun           bool   `help:"Show what would be installed without changing anything (exits non-zero if anything would change)"`
+		Symlink          bool   `help:"Install symlinks to the Quickhook executable instead of shim scripts"`
+		GlobalTemplate   bool   `help:"Install shims into a Git template directory and set init.templateDir, so new clones get them"`
+		TemplateDir      string `help:"Template directory to use with --global-template (default: init.templateDir or one in the user config directory)"`
+	} `cmd:"" help:"Install Quickhook shims into the Git hooks directory"`
+	Uninstall struct {
+		Yes       bool   `short:"y" help:"Assume yes for all prompts"`
+		HooksPath string `help:"Uninstall shims from this directory instead of the one Git uses"`
+	} `cmd:"" help:"Remove Quickhook shims and restore any hooks they replaced"`
 	Hook struct {
 		PreCommit struct {
-			Files []string `help:"For testing, supply list of files as changed files"`
+			All       bool     `short:"a" xor:"files" help:"Run on all Git-tracked files"`
+			Files     []string `xor:"files" help:"For testing, supply list of files as changed files"`
+			FromRef   string   `xor:"files" help:"Run on files changed between this ref and --to-ref"`
+			ToRef     string   `help:"End of the range started by --from-ref (default: HEAD)"`
+			Range     string   `xor:"files" help:"Run on files changed in a range of refs (eg. main..HEAD)"`
+			BatchSize int      `help:"Maximum number of files to pass to each execution of a hook (default: ${batch_size} with --all or a range, otherwise no limit)"`
 		} `cmd:"" help:"Run pre-commit hooks"`
 		CommitMsg struct {
 			MessageFile string `arg:"" help:"Temp file containing the commit message"`
 		} `cmd:"" help:"Run commit-msg hooks"`
+		PrepareCommitMsg struct {
+			MessageFile string `arg:"" help:"Temp file containing the commit message"`
+			Source      string `arg:"" optional:"" help:"Source of the commit message (message, template, merge, squash, or commit)"`
+			SHA         string `arg:"" optional:"" help:"SHA of the commit (when the source is commit)"`
+		} `cmd:"" help:"Run prepare-commit-msg hooks"`
+		PostCommit struct {
+			Foreground bool `help:"Run the hooks in the foreground instead of in the background"`
+		} `cmd:"" help:"Run post-commit hooks in the background"`
+		PostCheckout struct {
+			PreviousHead string `arg:"" help:"Ref of the previous HEAD"`
+			NewHead      string `arg:"" help:"Ref of the new HEAD"`
+			Flag         string `arg:"" help:"1 if it was a branch checkout, 0 if it was a file checkout"`
+		} `cmd:"" help:"Run post-checkout hooks"`
+		PostMerge struct {
+			Squash string `arg:"" help:"1 if it was a squash merge, 0 if not"`
+		} `cmd:"" help:"Run post-merge hooks"`
+		PrePush struct {
+			Remote string `arg:"" help:"Name of the remote being pushed to"`
+			URL    string `arg:"" help:"URL of the remote being pushed to"`
+		} `cmd:"" help:"Run pre-push hooks (reads ref updates from stdin)"`
+		PreReceive struct{} `cmd:"" help:"Run server-side pre-receive hooks (reads ref updates from stdin)"`
+		Update     struct {
+			Ref    string `arg:"" help:"Name of the ref being updated"`
+			OldSHA string `arg:"" name:"old-sha" help:"Old SHA of the ref"`
+			NewSHA string `arg:"" name:"new-sha" help:"New SHA of the ref"`
+		} `cmd:"" help:"Run server-side update hooks"`
+		PostReceive struct{} `cmd:"" help:"Run server-side post-receive hooks (reads ref updates from stdin)"`
+		Run         struct {
+			Name string   `arg:"" help:"Name of the Git hook"`
+			Args []string `arg:"" optional:"" passthrough:"" help:"Arguments to pass to the hook executables"`
+		} `cmd:"" help:"Run hooks for any Git hook, passing through arguments and stdin"`
 	} `cmd:""`
-	NoColor bool             `env:"NO_COLOR" help:"Don't colorize output"`
-	Trace   bool             `env:"QUICKHOOK_TRACE" help:"Enable tracing, writes to trace.out"`
-	Version kong.VersionFlag `help:"Show version information"`
+	Status struct {
+		JSON bool `name:"json" help:"Print the status as JSON"`
+	} `cmd:"" help:"Show installed shims and the hooks that will run for each Git hook"`
+	Doctor struct {
+		Fix bool `help:"Fix the problems which can be fixed automatically"`
+	} `cmd:"" help:"Check for problems with hooks, shims, and the environment"`
+	Logs struct {
+		Count int `short:"n" default:"5" help:"Number of logs to show"`
+	} `cmd:"" help:"Show logs from recent hooks that were run in the background"`
+	Verify struct {
+		Range string `arg:"" help:"Range of commits to verify (eg. origin/main..HEAD)"`
+	} `cmd:"" help:"Run pre-commit and commit-msg hooks on every commit in a range"`
+	NoColor     bool             `env:"NO_COLOR" help:"Don't colorize output"`
+	Trace       bool             `env:"QUICKHOOK_TRACE" help:"Enable tracing, writes to trace.out"`
+	AutoInstall bool             `env:"QUICKHOOK_AUTO_INSTALL" help:"When running hooks, update outdated shims and install missing ones"`
+	Timeout     time.Duration    `env:"QUICKHOOK_TIMEOUT" help:"Default timeout for each hook executable (eg. 30s), zero for none"`
+	FailFast    bool             `env:"QUICKHOOK_FAIL_FAST" help:"Cancel the other hook executables as soon as one fails"`
+	Jobs        int              `short:"j" env:"QUICKHOOK_JOBS" help:"Maximum number of hook executables to run at once, defaults to two more than the number of CPUs"`
+	Version     kong.VersionFlag `help:"Show version information"`
 }
 
 func main() {
 	parser, err := kong.New(&cli,
 		kong.Vars{
-			"version": VERSION,
-		})
+			"version":    VERSION,
+			"batch_size": strconv.Itoa(hooks.DEFAULT_BATCH_SIZE),
+		},
+		kong.Exit(exit))
 	if err != nil {
-		panic(err)
+		fatal(err)
 	}
 
 	args := os.Args[1:]
+	// Hooks installed with --symlink run this executable with the hook's name.
+	if name := filepath.Base(os.Args[0]); lo.Contains(hooks.GIT_HOOKS, name) {
+		args = hookArgs(name, args)
+		os.Setenv(SHIM_PATH_ENV, os.Args[0])
+		os.Setenv(SHIM_STAMP_ENV, shimStamp())
+	}
 	// Print the help if there are no args.
 	if len(args) == 0 {
 		parsed := kong.Context{
@@ -56,59 +136,365 @@ func main() {
 	parser.FatalIfErrorf(err)
 
 	if cli.Trace {
-		finish := tracing.Start()
-		defer finish()
+		internal.AddCleanup(tracing.Start())
 	}
+	handleSignals()
 
 	if cli.NoColor {
 		color.NoColor = true
 	}
+	hooks.DefaultTimeout = cli.Timeout
+	hooks.FailFast = cli.FailFast
+	hooks.Jobs = cli.Jobs
 
 	switch parsed.Command() {
 	case "install":
+		if cli.Install.GlobalTemplate {
+			installGlobalTemplateCommand(parsed)
+			break
+		}
 		repo, err := repo.NewRepo()
 		if err != nil {
-			panic(err)
+			fatal(err)
 		}
 
-		// TODO: Dry run option.
 		prompt := !cli.Install.Yes
 		quickhook := strings.TrimSpace(cli.Install.Bin)
-		if quickhook == "" {
+		if cli.Install.Symlink {
+			quickhook, err = symlinkTarget(quickhook)
+			if err != nil {
+				parsed.Fatalf("%v", err)
+			}
+		} else if quickhook == "" {
 			quickhook = "quickhook"
+		} else if err := checkQuickhookCommand(repo.Root, quickhook); err != nil {
+			if !cli.Install.DryRun {
+				parsed.Fatalf("--bin %v can't be found: %v", quickhook, err)
+			}
+			fmt.Fprintf(os.Stderr, "Warning: --bin %v can't be found: %v\n", quickhook, err)
 		}
-		err = install(repo, quickhook, prompt)
-		if err != nil {
-			panic(err)
+		err = install(repo, installOptions{
+			quickhook:        quickhook,
+			prompt:           prompt,
+			hooksPath:        cli.Install.HooksPath,
+			managedHooksPath: cli.Install.ManagedHooksPath,
+			dryRun:           cli.Install.DryRun,
+			symlink:          cli.Install.Symlink,
+			force:            cli.Install.Force,
+		})
+		if errors.Is(err, errInstallWouldChange) {
+			exit(1)
+		} else if err != nil {
+			fatal(err)
 		}
 
-	case "hook commit-msg <message-file>":
+	case "uninstall":
 		repo, err := repo.NewRepo()
 		if err != nil {
-			panic(err)
+			fatal(err)
 		}
 
+		err = uninstall(repo, uninstallOptions{
+			prompt:    !cli.Uninstall.Yes,
+			hooksPath: cli.Uninstall.HooksPath,
+		})
+		if err != nil {
+			fatal(err)
+		}
+
+	case "hook commit-msg <message-file>":
+		repo := newHookRepo("commit-msg")
+
 		hook := hooks.CommitMsg{
 			Repo: repo,
 		}
 		err = hook.Run(cli.Hook.CommitMsg.MessageFile)
+		checkHookError(err)
+
+	case "hook pre-commit":
+		repo := newHookRepo("pre-commit")
+
+		fromRef, toRef, err := preCommitRange()
 		if err != nil {
-			panic(err)
+			parsed.Fatalf("%v", err)
 		}
+		hook := hooks.PreCommit{
+			Repo:      repo,
+			All:       cli.Hook.PreCommit.All,
+			FromRef:   fromRef,
+			ToRef:     toRef,
+			BatchSize: cli.Hook.PreCommit.BatchSize,
+		}
+		err = hook.Run(cli.Hook.PreCommit.Files)
+		checkHookError(err)
 
-	case "hook pre-commit":
+	case "hook prepare-commit-msg <message-file>",
+		"hook prepare-commit-msg <message-file> <source>",
+		"hook prepare-commit-msg <message-file> <source> <sha>":
+		repo := newHookRepo("prepare-commit-msg")
+
+		hook := hooks.PrepareCommitMsg{Repo: repo}
+		args := cli.Hook.PrepareCommitMsg
+		err = hook.Run(args.MessageFile, args.Source, args.SHA)
+		checkHookError(err)
+
+	case "hook post-commit":
+		// The background process doesn't need to check the shims again.
+		stage := "post-commit"
+		if cli.Hook.PostCommit.Foreground {
+			stage = ""
+		}
+		repo := newHookRepo(stage)
+
+		hook := hooks.PostCommit{Repo: repo}
+		if cli.Hook.PostCommit.Foreground {
+			err = hook.RunForeground()
+		} else {
+			err = hook.Run()
+		}
+		checkHookError(err)
+
+	case "hook post-checkout <previous-head> <new-head> <flag>":
+		repo := newHookRepo("post-checkout")
+
+		hook := hooks.PostCheckout{Repo: repo}
+		args := cli.Hook.PostCheckout
+		err = hook.Run(args.PreviousHead, args.NewHead, args.Flag)
+		checkHookError(err)
+
+	case "hook post-merge <squash>":
+		repo := newHookRepo("post-merge")
+
+		hook := hooks.PostMerge{Repo: repo}
+		err = hook.Run(cli.Hook.PostMerge.Squash)
+		checkHookError(err)
+
+	case "hook pre-push <remote> <url>":
+		repo := newHookRepo("pre-push")
+
+		hook := hooks.PrePush{Repo: repo}
+		err = hook.Run(cli.Hook.PrePush.Remote, cli.Hook.PrePush.URL, os.Stdin)
+		checkHookError(err)
+
+	case "hook pre-receive", "hook post-receive":
+		repo := newHookRepo(strings.TrimPrefix(parsed.Command(), "hook "))
+
+		hook := hooks.Receive{
+			Repo: repo,
+			Hook: strings.TrimPrefix(parsed.Command(), "hook "),
+		}
+		err = hook.Run(os.Stdin)
+		checkHookError(err)
+
+	case "hook update <ref> <old-sha> <new-sha>":
+		repo := newHookRepo("update")
+
+		hook := hooks.Update{Repo: repo}
+		args := cli.Hook.Update
+		err = hook.Run(args.Ref, args.OldSHA, args.NewSHA)
+		checkHookError(err)
+
+	case "hook run <name>", "hook run <name> <args>":
+		name := cli.Hook.Run.Name
+		if !lo.Contains(hooks.GIT_HOOKS, name) {
+			parsed.Fatalf("unknown Git hook: %v", name)
+		}
+		repo := newHookRepo(name)
+
+		hook := hooks.Passthrough{Repo: repo}
+		// Drop the "--" separating our arguments from the hook's.
+		args := cli.Hook.Run.Args
+		if len(args) > 0 && args[0] == "--" {
+			args = args[1:]
+		}
+		err = hook.Run(name, args, os.Stdin)
+		checkHookError(err)
+
+	case "status":
 		repo, err := repo.NewRepo()
 		if err != nil {
-			panic(err)
+			fatal(err)
 		}
 
-		hook := hooks.PreCommit{Repo: repo}
-		err = hook.Run(cli.Hook.PreCommit.Files)
+		err = status(repo, cli.Status.JSON)
 		if err != nil {
-			panic(err)
+			fatal(err)
 		}
 
+	case "doctor":
+		repo, err := repo.NewRepo()
+		if err != nil {
+			fatal(err)
+		}
+
+		err = doctor(repo, cli.Doctor.Fix)
+		if errors.Is(err, errDoctorProblems) {
+			exit(1)
+		} else if err != nil {
+			fatal(err)
+		}
+
+	case "logs":
+		repo, err := repo.NewRepo()
+		if err != nil {
+			fatal(err)
+		}
+
+		err = logs(repo, cli.Logs.Count)
+		if err != nil {
+			fatal(err)
+		}
+
+	case "verify <range>":
+		fromRef, toRef, err := repo.ParseRange(cli.Verify.Range)
+		if err != nil {
+			parsed.Fatalf("%v", err)
+		}
+		repo, err := repo.NewRepo()
+		if err != nil {
+			fatal(err)
+		}
+
+		err = verify(repo, fromRef, toRef)
+		checkHookError(err)
+
 	default:
-		panic(fmt.Sprintf("Unrecognized command: %v", parsed.Command()))
+		fatal(fmt.Errorf("unrecognized command: %v", parsed.Command()))
+	}
+	exit(0)
+}
+
+// Prints the error and exits. Used instead of panicking so that the cleanups still run.
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "quickhook: error: %v\n", err)
+	exit(1)
+}
+
+var exitOnce sync.Once
+
+// Every exit goes through here so that the cleanups (eg. removing temporary files and writing the
+// trace) always run. If Quickhook was interrupted then it exits with the conventional code for
+// the signal instead.
+func exit(code int) {
+	exitOnce.Do(func() {
+		internal.RunCleanups()
+		if interrupted := hooks.Interrupted(); interrupted != 0 {
+			code = 128 + int(interrupted)
+		}
+		os.Exit(code)
+	})
+}
+
+// How long to wait for hooks to exit after forwarding a signal to them before killing them.
+const SIGNAL_WAIT = 2 * time.Second
+
+// Forwards SIGINT and SIGTERM to the hooks (see hooks.Interrupt), waits briefly for them to exit,
+// then exits.
+func handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		received := (<-signals).(syscall.Signal)
+		hooks.Interrupt(received)
+		hooks.WaitForExecutables(SIGNAL_WAIT)
+		exit(128 + int(received))
+	}()
+}
+
+func installGlobalTemplateCommand(parsed *kong.Context) {
+	flags := cli.Install
+	if flags.HooksPath != "" || flags.ManagedHooksPath || flags.DryRun || flags.Symlink {
+		parsed.Fatalf("--global-template can't be used with --hooks-path, --managed-hooks-path, --dry-run, or --symlink")
+	}
+	quickhook := strings.TrimSpace(flags.Bin)
+	if quickhook == "" {
+		quickhook = "quickhook"
+	} else if err := checkQuickhookCommand("", quickhook); err != nil {
+		parsed.Fatalf("--bin %v can't be found: %v", quickhook, err)
+	}
+	err := installGlobalTemplate(templateOptions{
+		quickhook:   quickhook,
+		prompt:      !flags.Yes,
+		templateDir: flags.TemplateDir,
+	})
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func logs(repo *repo.Repo, count int) error {
+	logs, err := hooks.RecentLogs(repo, count)
+	if err != nil {
+		return err
+	}
+	if len(logs) == 0 {
+		fmt.Println("No logs found")
+		return nil
+	}
+	for index, log := range logs {
+		data, err := os.ReadFile(log)
+		if err != nil {
+			return err
+		}
+		if index > 0 {
+			fmt.Println()
+		}
+		fmt.Println(color.New(color.Bold).Sprint(log))
+		fmt.Print(string(data))
+	}
+	return nil
+}
+
+// Opens the repository for running a hook, first checking that the shims are up to date (unless
+// the hook is empty).
+func newHookRepo(hook string) *repo.Repo {
+	repo, err := repo.NewRepo()
+	if err != nil {
+		fatal(err)
+	}
+	if hook != "" {
+		err = checkShims(repo, hook, cli.AutoInstall)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	return repo
+}
+
+// Exits with FAILED_EXIT_CODE if hooks failed, or prints the error and exits if there was any
+// other error.
+func checkHookError(err error) {
+	if errors.Is(err, hooks.ErrFailed) {
+		exit(hooks.FAILED_EXIT_CODE)
+	} else if err != nil {
+		fatal(err)
+	}
+}
+
+// Resolves the --from-ref, --to-ref, and --range flags to the refs to diff between. Returns empty
+// refs if none of them were given.
+func preCommitRange() (string, string, error) {
+	flags := cli.Hook.PreCommit
+	if flags.Range != "" {
+		if flags.ToRef != "" {
+			return "", "", errors.New("--to-ref can't be used with --range")
+		}
+		return repo.ParseRange(flags.Range)
+	}
+	if flags.FromRef == "" {
+		if flags.ToRef != "" {
+			return "", "", errors.New("--to-ref requires --from-ref")
+		}
+		return "", "", nil
+	}
+	toRef := flags.ToRef
+	if toRef == "" {
+		toRef = "HEAD"
+	}
+	for _, ref := range []string{flags.FromRef, toRef} {
+		if err := repo.CheckRef(ref); err != nil {
+			return "", "", err
+		}
 	}
+	return flags.FromRef, toRef, nil
 }
@@ -1,6 +1,11 @@
 package repo
 
 import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"strings"
+
 	"github.com/samber/lo"
 
 	"github.com/dirk/quickhook/tracing"
@@ -13,8 +18,240 @@ func (repo *Repo) FilesToBeCommitted() ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	return lo.Filter(lines, func(line string, index int) bool {
-		isFile, _ := repo.isFile(line)
+	return repo.filterFiles(lines), nil
+}
+
+// Lists every file tracked by Git, excluding any which have been deleted from the working tree.
+func (repo *Repo) TrackedFiles() ([]string, error) {
+	span := tracing.NewSpan("git ls-files")
+	defer span.End()
+	lines, err := repo.ExecCommandLines("git", "ls-files")
+	if err != nil {
+		return nil, err
+	}
+	return repo.filterFiles(lines), nil
+}
+
+// Like TrackedFiles, but calls each with batches of at most size files as Git lists them rather
+// than collecting them all first.
+func (repo *Repo) TrackedFileBatches(size int, each func(files []string) error) error {
+	return repo.fileBatches(size, each, "ls-files")
+}
+
+// Like FilesChangedBetween, but calls each with batches of at most size files as Git lists them
+// rather than collecting them all first.
+func (repo *Repo) FilesChangedBetweenBatches(fromRef, toRef string, size int, each func(files []string) error) error {
+	return repo.fileBatches(size, each,
+		"diff", "--name-only", "--diff-filter=d", "--end-of-options", fmt.Sprintf("%s...%s", fromRef, toRef))
+}
+
+// Lists the files changed between the merge base of the two refs and the second ref (ie. the
+// same as `git diff A...B`), excluding any which were deleted by the second ref or have been
+// deleted from the working tree.
+func (repo *Repo) FilesChangedBetween(fromRef, toRef string) ([]string, error) {
+	span := tracing.NewSpan("git diff range")
+	defer span.End()
+	lines, err := repo.ExecCommandLines(
+		"git", "diff", "--name-only", "--diff-filter=d", "--end-of-options", fmt.Sprintf("%s...%s", fromRef, toRef))
+	if err != nil {
+		return nil, err
+	}
+	return repo.filterFiles(lines), nil
+}
+
+// Lists the files changed by a ref update received by the repository (eg. in the pre-receive
+// hook), excluding files deleted by the commit which changed them. This works in bare repositories
+// since it doesn't look at the working tree. It lists the files changed by each of the new commits
+// (ie. ones reachable from the new SHA but not the old one), so files which were added and then
+// removed are included. If the ref is new then the new commits are the ones which aren't already
+// on any other ref. (HEAD is deliberately not considered, since in the post-receive hook it may
+// point to the new ref.)
+func (repo *Repo) FilesChangedInUpdate(ref, oldSHA, newSHA string) ([]string, error) {
+	if IsNullSHA(newSHA) {
+		return []string{}, nil
+	}
+	span := tracing.NewSpan("git log update")
+	defer span.End()
+	if IsNullSHA(oldSHA) {
+		return repo.filesChangedByCommits(newSHA, "--exclude="+ref, "--glob=refs/*")
+	}
+	return repo.filesChangedByCommits(newSHA, oldSHA)
+}
+
+// Lists the files changed by the commits reachable from the SHA but not from any of the excluded
+// revisions (eg. other SHAs or --remotes), excluding files deleted by the commit which changed them.
+func (repo *Repo) filesChangedByCommits(sha string, exclude ...string) ([]string, error) {
+	lines, err := repo.ExecCommandLines(
+		"git", append([]string{"log", "--format=", "--name-only", "--diff-filter=d", sha, "--not"}, exclude...)...)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Uniq(lo.Compact(lines)), nil
+}
+
+// Runs `git diff --name-only` with the arguments. Unlike the other methods for listing files this
+// includes deleted files.
+func (repo *Repo) DiffNames(arg ...string) ([]string, error) {
+	span := tracing.NewSpan("git diff names")
+	defer span.End()
+	lines, err := repo.ExecCommandLines("git", append([]string{"diff", "--name-only"}, arg...)...)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Compact(lines), nil
+}
+
+// Returns the SHA of the empty tree, which can be diffed against when there's no previous commit.
+func (repo *Repo) EmptyTree() (string, error) {
+	return repo.ExecCommand("git", "hash-object", "-t", "tree", "/dev/null")
+}
+
+// Lists the files changed by a commit, excluding any which have been deleted from the working
+// tree. Merge commits are compared with their first parent.
+func (repo *Repo) FilesChangedInCommit(commit string) ([]string, error) {
+	span := tracing.NewSpan("git diff-tree")
+	defer span.End()
+	lines, err := repo.ExecCommandLines(
+		"git", "diff-tree", "--no-commit-id", "--name-only", "-r", "--root", "--diff-merges=first-parent", commit)
+	if err != nil {
+		return nil, err
+	}
+	return repo.filterFiles(lines), nil
+}
+
+// Lists the commits reachable from toRef but not fromRef, oldest first.
+func (repo *Repo) CommitsInRange(fromRef, toRef string) ([]string, error) {
+	lines, err := repo.ExecCommandLines(
+		"git", "rev-list", "--reverse", "--end-of-options", fmt.Sprintf("%s..%s", fromRef, toRef))
+	if err != nil {
+		return nil, err
+	}
+	return lo.Compact(lines), nil
+}
+
+// Returns the full SHA of the commit a ref points to.
+func (repo *Repo) RevParse(ref string) (string, error) {
+	return repo.ExecCommand("git", "rev-parse", "--verify", "--quiet", ref)
+}
+
+// Returns the value of a --format placeholder (eg. "%B" for the message) for a commit.
+func (repo *Repo) CommitFormat(commit, format string) (string, error) {
+	return repo.ExecCommand("git", "log", "-1", "--format="+format, commit)
+}
+
+// Lists the files changed by the commits that a push would send to the remote: ones reachable from
+// localSHA but not remoteSHA. If the remote ref is new (or its commit isn't available locally)
+// then it lists the commits not already on any of the remote's refs, or on any remote-tracking
+// refs if the remote doesn't have any (eg. when pushing to a URL).
+func (repo *Repo) FilesChangedInPush(remote, localSHA, remoteSHA string) ([]string, error) {
+	span := tracing.NewSpan("git log push")
+	defer span.End()
+	exclude := "--remotes"
+	if !IsNullSHA(remoteSHA) && repo.hasCommit(remoteSHA) {
+		exclude = remoteSHA
+	} else if repo.hasRemoteRefs(remote) {
+		exclude = "--remotes=" + remote
+	}
+	files, err := repo.filesChangedByCommits(localSHA, exclude)
+	if err != nil {
+		return nil, err
+	}
+	return repo.filterFiles(files), nil
+}
+
+func (repo *Repo) hasRemoteRefs(remote string) bool {
+	output, err := repo.ExecCommand(
+		"git", "for-each-ref", "--count=1", "--format=%(refname)", "refs/remotes/"+remote+"/")
+	return err == nil && output != ""
+}
+
+func (repo *Repo) hasCommit(sha string) bool {
+	_, err := repo.ExecCommand("git", "cat-file", "-e", sha+"^{commit}")
+	return err == nil
+}
+
+// Returns true if the SHA is all zeroes, which Git uses for refs that are being created or
+// deleted.
+func IsNullSHA(sha string) bool {
+	return sha != "" && strings.Trim(sha, "0") == ""
+}
+
+// Splits a range like "A..B" or "A...B" into its two refs. Either side may be omitted, in which
+// case it defaults to HEAD like it does in Git.
+func ParseRange(spec string) (string, string, error) {
+	separator := "..."
+	if !strings.Contains(spec, separator) {
+		separator = ".."
+	}
+	fromRef, toRef, found := strings.Cut(spec, separator)
+	if !found {
+		return "", "", fmt.Errorf("invalid range (expected A..B): %v", spec)
+	}
+	if fromRef == "" {
+		fromRef = "HEAD"
+	}
+	if toRef == "" {
+		toRef = "HEAD"
+	}
+	for _, ref := range []string{fromRef, toRef} {
+		if err := CheckRef(ref); err != nil {
+			return "", "", err
+		}
+	}
+	return fromRef, toRef, nil
+}
+
+// Returns an error if the ref would be mistaken for an option by Git.
+func CheckRef(ref string) error {
+	if strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("invalid ref (can't start with \"-\"): %v", ref)
+	}
+	return nil
+}
+
+// Runs the Git command and calls each with batches of at most size of the names it outputs which
+// are files in the working tree. If each returns an error then the command is killed and the error
+// is returned.
+func (repo *Repo) fileBatches(size int, each func(files []string) error, arg ...string) error {
+	cmd := exec.Command("git", arg...)
+	cmd.Dir = repo.Root
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	batch := []string{}
+	scanner := bufio.NewScanner(stdout)
+	for err == nil && scanner.Scan() {
+		if isFile, _ := repo.isFile(scanner.Text()); isFile {
+			batch = append(batch, scanner.Text())
+		}
+		if len(batch) >= size {
+			err = each(batch)
+			batch = []string{}
+		}
+	}
+	if err == nil {
+		err = scanner.Err()
+	}
+	if err == nil && len(batch) > 0 {
+		err = each(batch)
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	return cmd.Wait()
+}
+
+// Keeps only the names which are files in the working tree (ie. not deleted and not directories).
+func (repo *Repo) filterFiles(names []string) []string {
+	return lo.Filter(names, func(name string, index int) bool {
+		isFile, _ := repo.isFile(name)
 		return isFile
-	}), err
+	})
 }
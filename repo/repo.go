@@ -6,21 +6,36 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/dirk/quickhook/tracing"
 )
 
 type Repo struct {
-	// Root directory of the repository.
+	// Root directory of the repository. For bare repositories this is the Git directory.
 	Root string
+	// Bare repositories (eg. on a Git server) don't have a working tree.
+	Bare bool
 }
 
 func NewRepo() (*Repo, error) {
 	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return nil, err
+		// Finding the top level fails in bare repositories, so check if that's why.
+		bareOutput, bareErr := exec.Command("git", "rev-parse", "--is-bare-repository", "--absolute-git-dir").Output()
+		if bareErr != nil {
+			return nil, err
+		}
+		lines := strings.Split(strings.TrimSpace(string(bareOutput)), "\n")
+		if len(lines) != 2 || lines[0] != "true" {
+			return nil, err
+		}
+		return &Repo{
+			Root: lines[1],
+			Bare: true,
+		}, nil
 	}
 
 	return &Repo{
@@ -28,19 +43,34 @@ func NewRepo() (*Repo, error) {
 	}, nil
 }
 
+// Lists the executables to run for the hook, warning about any files in its directory which
+// aren't executable.
 func (repo *Repo) FindHookExecutables(hook string) ([]string, error) {
 	span := tracing.NewSpan("find " + hook)
 	defer span.End()
 
+	executables, nonExecutables, err := repo.ListHookFiles(hook)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range nonExecutables {
+		fmt.Fprintf(os.Stderr, "Warning: Non-executable file found in %v: %v\n", path.Dir(name), path.Base(name))
+	}
+	return executables, nil
+}
+
+// Lists the files in the hook's directory in .quickhook (relative to the root), split into
+// executables and files which aren't executable.
+func (repo *Repo) ListHookFiles(hook string) ([]string, []string, error) {
 	dir := path.Join(".quickhook", hook)
 	var infos []fs.FileInfo
 	{
 		f, err := os.Open(path.Join(repo.Root, dir))
 		if err != nil {
 			if os.IsNotExist(err) {
-				return []string{}, nil
+				return []string{}, []string{}, nil
 			}
-			return nil, err
+			return nil, nil, err
 		}
 		defer f.Close()
 
@@ -48,23 +78,122 @@ func (repo *Repo) FindHookExecutables(hook string) ([]string, error) {
 		// returns returns DirEntry's which does not.
 		infos, err = f.Readdir(-1)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	hooks := []string{}
+	executables := []string{}
+	nonExecutables := []string{}
 	for _, info := range infos {
 		if info.IsDir() {
 			continue
 		}
-		name := info.Name()
+		name := path.Join(dir, info.Name())
 		if (info.Mode() & 0111) != 0 {
-			hooks = append(hooks, path.Join(dir, name))
+			executables = append(executables, name)
 		} else {
-			fmt.Fprintf(os.Stderr, "Warning: Non-executable file found in %v: %v\n", dir, name)
+			nonExecutables = append(nonExecutables, name)
 		}
 	}
-	return hooks, nil
+	return executables, nonExecutables, nil
+}
+
+// Returns the directory where Quickhook keeps its own state (eg. logs). It's inside the Git
+// directory shared by all worktrees.
+func (repo *Repo) QuickhookDir() (string, error) {
+	commonDir, err := repo.ExecCommand("git", "rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+	return path.Join(commonDir, "quickhook"), nil
+}
+
+// Returns the directory where Git looks for hooks. This takes into account worktrees (where the
+// hooks are in the main repository's Git directory), submodules, and core.hooksPath.
+func (repo *Repo) HooksDir() (string, error) {
+	return repo.ExecCommand("git", "rev-parse", "--path-format=absolute", "--git-path", "hooks")
+}
+
+// Returns the value of a Git config key, or an empty string if it isn't set.
+func (repo *Repo) ConfigGet(key string) (string, error) {
+	value, err := repo.ExecCommand("git", "config", "--get", key)
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		// Git exits with 1 if the key isn't set.
+		return "", nil
+	}
+	return value, err
+}
+
+// Sets a Git config key in the repository's local config.
+func (repo *Repo) ConfigSet(key, value string) error {
+	_, err := repo.ExecCommand("git", "config", "--local", key, value)
+	return err
+}
+
+// Removes a Git config key from the repository's local config.
+func (repo *Repo) ConfigUnset(key string) error {
+	_, err := repo.ExecCommand("git", "config", "--local", "--unset", key)
+	return err
+}
+
+// Returns the value of a key (expanding "~" since it's a path) from the user's global Git config,
+// or an empty string if it isn't set.
+func GlobalConfigGetPath(key string) (string, error) {
+	output, err := exec.Command("git", "config", "--global", "--get", "--type=path", key).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Sets a key in the user's global Git config.
+func GlobalConfigSet(key, value string) error {
+	return exec.Command("git", "config", "--global", key, value).Run()
+}
+
+// Returns the absolute path of a name relative to the root. Absolute names are returned unchanged.
+func (repo *Repo) Abs(name string) string {
+	if path.IsAbs(name) {
+		return name
+	}
+	return path.Join(repo.Root, name)
+}
+
+// Returns the path relative to the root if it's inside of it, otherwise returns the absolute path.
+func (repo *Repo) Rel(name string) string {
+	rel, err := filepath.Rel(repo.Root, repo.Abs(name))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return repo.Abs(name)
+	}
+	return rel
+}
+
+// Creates a new worktree in dir with the commit checked out (with a detached HEAD) and returns
+// a Repo for it. Remove it with RemoveWorktree when finished.
+func (repo *Repo) AddWorktree(dir, commit string) (*Repo, error) {
+	_, err := repo.ExecCommand("git", "worktree", "add", "--detach", "--quiet", dir, commit)
+	if err != nil {
+		return nil, err
+	}
+	return &Repo{Root: dir}, nil
+}
+
+func (repo *Repo) RemoveWorktree(dir string) error {
+	_, err := repo.ExecCommand("git", "worktree", "remove", "--force", dir)
+	return err
+}
+
+// Checks out the commit with a detached HEAD, discarding any changes and untracked files in the
+// working tree (eg. from hooks which format files).
+func (repo *Repo) CheckoutDetached(commit string) error {
+	_, err := repo.ExecCommand("git", "checkout", "--force", "--detach", "--quiet", commit)
+	if err != nil {
+		return err
+	}
+	_, err = repo.ExecCommand("git", "clean", "-fdx", "--quiet")
+	return err
 }
 
 // Runs a command with the repo root as the current working directory. Returns the command's
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/dirk/quickhook/internal"
+	"github.com/dirk/quickhook/repo"
+	"github.com/dirk/quickhook/tracing"
+)
+
+// Version of the shim script. Bump this whenever shimContent changes in a way that existing shims
+// should be regenerated for.
+const SHIM_FORMAT = 4
+
+// Environment variables shims set when running Quickhook: the path of the shim and its stamp (see
+// shimStamp). These let Quickhook check the shim that's running without having to find and read it.
+const SHIM_PATH_ENV = "QUICKHOOK_SHIM"
+const SHIM_STAMP_ENV = "QUICKHOOK_SHIM_STAMP"
+
+// Finds the Quickhook command in shims which predate the quickhook-bin directive, eg. the
+// "quickhook" in "exec quickhook hook pre-commit".
+var shimCommandRegexp = regexp.MustCompile(`(?m)^(?:exec )?([^#\s][^\n]*?) hook [a-z-]+`)
+
+// What a shim records about the Quickhook which generated it.
+type shimInfo struct {
+	version string
+	// 1 for shims from before the format was recorded.
+	format int
+	// Quickhook command the shim runs (or the executable a symlink points to).
+	quickhook string
+	// Installed with --symlink.
+	symlink bool
+}
+
+// Returns true if the shim should be regenerated: if it was generated by another version of
+// Quickhook, or runs a different command to the running shim (if that's given).
+func (info *shimInfo) outdated(running *shimInfo) bool {
+	if info.symlink {
+		// Symlinks always run the executable they point to, so they can't be outdated.
+		return false
+	}
+	if info.format < SHIM_FORMAT || info.version != VERSION {
+		return true
+	}
+	return running != nil && !running.symlink && info.quickhook != running.quickhook
+}
+
+// Identifies the shims generated by this version of Quickhook, eg. "4 v1.2.3".
+func shimStamp() string {
+	return fmt.Sprintf("%d %s", SHIM_FORMAT, VERSION)
+}
+
+// Reads the directives from a shim. Returns nil if the file isn't a Quickhook shim.
+func readShim(fullPath string) (*shimInfo, error) {
+	isShim, err := isQuickhookShim(fullPath)
+	if err != nil || !isShim {
+		return nil, err
+	}
+	if target, err := os.Readlink(fullPath); err == nil {
+		// Symlinks always run the executable they point to, so they can't be outdated.
+		return &shimInfo{format: SHIM_FORMAT, quickhook: target, symlink: true}, nil
+	}
+	directives, err := internal.ReadDirectives(fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &shimInfo{
+		version:   directives.Get("version"),
+		format:    1,
+		quickhook: directives.Get("bin"),
+	}
+	if format := directives.Get("shim-format"); format != "" {
+		info.format, err = strconv.Atoi(format)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shim format in %v: %v", fullPath, format)
+		}
+	}
+	if info.quickhook == "" {
+		data, err := os.ReadFile(fullPath)
+		if err != nil {
+			return nil, err
+		}
+		if match := shimCommandRegexp.FindStringSubmatch(string(data)); match != nil {
+			info.quickhook = match[1]
+		} else {
+			info.quickhook = "quickhook"
+		}
+	}
+	return info, nil
+}
+
+// Checks the shims when running a hook: warns if the running shim is outdated or if hook
+// directories in .quickhook don't have a shim (eg. because a teammate added them). This only
+// happens when the hook was run by a shim which set SHIM_PATH_ENV, so that running hooks by hand
+// doesn't produce warnings, and it doesn't read any shims since it's run for every hook.
+//
+// If autoInstall is true then it instead reads every shim, and regenerates those which are
+// outdated or missing.
+func checkShims(repo *repo.Repo, hook string, autoInstall bool) error {
+	span := tracing.NewSpan("check shims")
+	defer span.End()
+
+	shimPath, stamp := os.Getenv(SHIM_PATH_ENV), os.Getenv(SHIM_STAMP_ENV)
+	// Don't pass them on to the hook executables (or any Quickhook they run).
+	os.Unsetenv(SHIM_PATH_ENV)
+	os.Unsetenv(SHIM_STAMP_ENV)
+
+	if _, err := os.Stat(path.Join(repo.Root, ".quickhook")); err != nil {
+		return nil
+	}
+	if autoInstall {
+		return autoInstallShims(repo, hook, shimPath)
+	}
+	if shimPath == "" {
+		return nil
+	}
+	fullPath, err := filepath.Abs(shimPath)
+	if err != nil {
+		return err
+	}
+
+	if stamp != shimStamp() {
+		fmt.Fprintf(os.Stderr,
+			"Warning: Shim %v is outdated, run `quickhook install` to update it\n", repo.Rel(fullPath))
+	}
+	names, err := listHooks(repo)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, err := os.Lstat(path.Join(path.Dir(fullPath), name)); os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr,
+				"Warning: Found hooks in .quickhook/%v but no shim for them, run `quickhook install` to install it\n",
+				name)
+		}
+	}
+	return nil
+}
+
+// Regenerates the shims which are outdated compared to the running one, and installs missing
+// ones. The running shim's path is looked up if it's not given (eg. because it's too old to set
+// SHIM_PATH_ENV); nothing is changed if it's not a Quickhook shim.
+func autoInstallShims(repo *repo.Repo, hook, shimPath string) error {
+	var hooksDir string
+	if shimPath != "" {
+		fullPath, err := filepath.Abs(shimPath)
+		if err != nil {
+			return err
+		}
+		hooksDir = path.Dir(fullPath)
+	} else {
+		var err error
+		hooksDir, err = repo.HooksDir()
+		if err != nil {
+			return err
+		}
+	}
+	current, err := readShim(path.Join(hooksDir, hook))
+	if os.IsNotExist(err) || (err == nil && current == nil) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	names, err := listHooks(repo)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fullPath := path.Join(hooksDir, name)
+		shimPath := repo.Rel(fullPath)
+		info, err := readShim(fullPath)
+		missing := os.IsNotExist(err)
+		if err != nil && !missing {
+			return err
+		}
+		// Leave alone hooks which aren't ours and shims which are up to date.
+		if !missing && (info == nil || !info.outdated(current)) {
+			continue
+		}
+
+		like := current
+		if info != nil {
+			like = info
+			// Use the running shim's command so that they all end up the same.
+			like.quickhook = current.quickhook
+		}
+		err = reinstallShim(repo, shimPath, name, like)
+		if err != nil {
+			return err
+		}
+		if missing {
+			fmt.Fprintf(os.Stderr, "Installed shim %v\n", shimPath)
+		} else {
+			fmt.Fprintf(os.Stderr, "Updated shim %v\n", shimPath)
+		}
+	}
+	return nil
+}
+
+// Installs the shim the same way as an existing one: as a symlink to the same executable if that's
+// a symlink, otherwise as a shim script running the same command.
+func reinstallShim(repo *repo.Repo, shimPath, hook string, like *shimInfo) error {
+	if like.symlink {
+		linked, err := installSymlink(repo, shimPath, like.quickhook)
+		if err != nil || linked {
+			return err
+		}
+	}
+	return installShim(repo, shimPath, like.quickhook, hook)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallRecordsShimInfo(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--bin=go tool quickhook")
+	require.NoError(t, err)
+
+	info, err := readShim(path.Join(tempDir.Root, ".git", "hooks", "pre-commit"))
+	assert.NoError(t, err)
+	assert.Equal(t, &shimInfo{version: VERSION, format: SHIM_FORMAT, quickhook: "go tool quickhook"}, info)
+}
+
+func TestReadLegacyShim(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.MkdirAll(".git", "hooks")
+	tempDir.WriteFile([]string{".git", "hooks", "pre-commit"}, "#!/bin/sh\n/usr/local/bin/quickhook hook pre-commit\n")
+	tempDir.WriteFile([]string{".git", "hooks", "commit-msg"}, "#!/bin/sh\necho \"Not ours\"\n")
+
+	info, err := readShim(path.Join(tempDir.Root, ".git", "hooks", "pre-commit"))
+	assert.NoError(t, err)
+	assert.Equal(t, &shimInfo{format: 1, quickhook: "/usr/local/bin/quickhook"}, info)
+
+	info, err = readShim(path.Join(tempDir.Root, ".git", "hooks", "commit-msg"))
+	assert.NoError(t, err)
+	assert.Nil(t, info)
+}
+
+func TestHookWarnsAboutOutdatedAndMissingShims(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--bin="+tempDir.Quickhook)
+	require.NoError(t, err)
+	// Pretend the shim was generated by an older version.
+	shim, err := os.ReadFile(path.Join(tempDir.Root, ".git", "hooks", "pre-commit"))
+	require.NoError(t, err)
+	tempDir.WriteFile(
+		[]string{".git", "hooks", "pre-commit"},
+		strings.Replace(string(shim), "'"+shimStamp()+"'", "'3 v1.0.0'", 1))
+	tempDir.MkdirAll(".quickhook", "commit-msg")
+	tempDir.WriteFile([]string{".quickhook", "commit-msg", "fails"}, "#!/bin/sh \n echo \"commit-msg ran\" \n exit 1")
+
+	tempDir.WriteFile([]string{"example.txt"}, "Changed!")
+	tempDir.RequireExec("git", "add", "example.txt")
+	output, err := tempDir.NewCommand("git", "commit", "--quiet", "--message", "Commit example.txt").CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"Warning: Shim .git/hooks/pre-commit is outdated, run `quickhook install` to update it\n"+
+			"Warning: Found hooks in .quickhook/commit-msg but no shim for them, run `quickhook install` to install it\n",
+		string(output))
+	assert.NoFileExists(t, path.Join(tempDir.Root, ".git", "hooks", "commit-msg"))
+}
+
+func TestHookAutoInstallsShims(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--bin="+tempDir.Quickhook)
+	require.NoError(t, err)
+	tempDir.WriteFile([]string{".git", "hooks", "pre-commit"}, "#!/bin/sh\n"+tempDir.Quickhook+" hook pre-commit\n")
+	tempDir.MkdirAll(".quickhook", "commit-msg")
+	tempDir.WriteFile([]string{".quickhook", "commit-msg", "fails"}, "#!/bin/sh \n echo \"commit-msg ran\" \n exit 1")
+
+	tempDir.WriteFile([]string{"example.txt"}, "Changed!")
+	tempDir.RequireExec("git", "add", "example.txt")
+	cmd := tempDir.NewCommand("git", "commit", "--quiet", "--message", "Commit example.txt")
+	cmd.Env = append(os.Environ(), "QUICKHOOK_AUTO_INSTALL=1")
+	output, err := cmd.CombinedOutput()
+	// The commit-msg shim was installed while running pre-commit, so Git runs it (and it fails).
+	assert.Error(t, err)
+	assert.Equal(t,
+		"Installed shim .git/hooks/commit-msg\nUpdated shim .git/hooks/pre-commit\nfails: commit-msg ran\n",
+		string(output))
+
+	for _, hook := range []string{"pre-commit", "commit-msg"} {
+		info, err := readShim(path.Join(tempDir.Root, ".git", "hooks", hook))
+		assert.NoError(t, err)
+		assert.Equal(t, &shimInfo{version: VERSION, format: SHIM_FORMAT, quickhook: tempDir.Quickhook}, info)
+	}
+}
+
+func TestHookDoesntReadShimsWithoutAutoInstall(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--bin="+tempDir.Quickhook)
+	require.NoError(t, err)
+	tempDir.MkdirAll(".quickhook", "commit-msg")
+	tempDir.WriteFile([]string{".quickhook", "commit-msg", "passes"}, "#!/bin/sh \n exit 0")
+	// Other shims aren't read when running pre-commit, so this unreadable one doesn't matter.
+	tempDir.MkdirAll(".git", "hooks", "commit-msg")
+
+	tempDir.WriteFile([]string{"example.txt"}, "Changed!")
+	tempDir.RequireExec("git", "add", "example.txt")
+	output, err := tempDir.ExecQuickhook("hook", "pre-commit")
+	assert.NoError(t, err)
+	assert.Equal(t, "", output)
+
+	cmd := tempDir.NewCommand(tempDir.Quickhook, "hook", "pre-commit")
+	cmd.Env = append(os.Environ(),
+		SHIM_PATH_ENV+"="+path.Join(tempDir.Root, ".git", "hooks", "pre-commit"),
+		SHIM_STAMP_ENV+"="+shimStamp())
+	commitOutput, err := cmd.CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(commitOutput))
+}
+
+func TestShimOutdated(t *testing.T) {
+	running := &shimInfo{version: VERSION, format: SHIM_FORMAT, quickhook: "quickhook"}
+	assert.False(t, (&shimInfo{version: VERSION, format: SHIM_FORMAT, quickhook: "quickhook"}).outdated(running))
+	assert.True(t, (&shimInfo{version: VERSION, format: SHIM_FORMAT - 1, quickhook: "quickhook"}).outdated(running))
+	assert.True(t, (&shimInfo{version: "v0.1.0", format: SHIM_FORMAT, quickhook: "quickhook"}).outdated(running))
+	assert.True(t, (&shimInfo{version: VERSION, format: SHIM_FORMAT, quickhook: "go tool quickhook"}).outdated(running))
+	assert.False(t, (&shimInfo{version: VERSION, format: SHIM_FORMAT, quickhook: "go tool quickhook"}).outdated(nil))
+	assert.False(t, (&shimInfo{format: SHIM_FORMAT, quickhook: "/bin/quickhook", symlink: true}).outdated(running))
+}
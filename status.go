@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/fatih/color"
+	"github.com/samber/lo"
+
+	"github.com/dirk/quickhook/hooks"
+	"github.com/dirk/quickhook/repo"
+)
+
+const (
+	SHIM_INSTALLED = "installed"
+	SHIM_OUTDATED  = "outdated"
+	SHIM_MISSING   = "missing"
+	// There's a hook which wasn't generated by Quickhook.
+	SHIM_OTHER = "other"
+)
+
+type statusReport struct {
+	HooksDir string       `json:"hooks_dir"`
+	Hooks    []hookStatus `json:"hooks"`
+}
+
+type hookStatus struct {
+	Hook string `json:"hook"`
+	// Path of the shim, relative to the root if it's inside the repository.
+	Shim       string `json:"shim"`
+	ShimStatus string `json:"shim_status"`
+	// What generated the shim, if Quickhook did.
+	Version   string `json:"version,omitempty"`
+	Format    int    `json:"format,omitempty"`
+	Quickhook string `json:"quickhook,omitempty"`
+	Symlink   bool   `json:"symlink,omitempty"`
+	// Whether there's a legacy hook which the shim runs first.
+	Legacy bool `json:"legacy"`
+	// Files in .quickhook (relative to the root) which will be run, and ones which won't be since
+	// they aren't executable.
+	Executables    []string `json:"executables"`
+	NonExecutables []string `json:"non_executables"`
+}
+
+// Reports every hook which either has hooks in .quickhook or has something installed in the hooks
+// directory.
+func status(repo *repo.Repo, asJSON bool) error {
+	report, err := collectStatus(repo)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	fmt.Printf("Hooks directory: %v\n", report.HooksDir)
+	if len(report.Hooks) == 0 {
+		fmt.Println("No hooks found")
+	}
+	bold := color.New(color.Bold)
+	for _, hook := range report.Hooks {
+		fmt.Printf("%v: %v\n", bold.Sprint(hook.Hook), describeShim(hook))
+		for _, executable := range hook.Executables {
+			fmt.Printf("  %v\n", executable)
+		}
+		for _, name := range hook.NonExecutables {
+			fmt.Printf("  %v %v\n", name, color.YellowString("(not executable)"))
+		}
+	}
+	return nil
+}
+
+func describeShim(hook hookStatus) string {
+	var description string
+	switch hook.ShimStatus {
+	case SHIM_MISSING:
+		if len(hook.Executables) == 0 {
+			return "no shim"
+		}
+		return color.RedString("no shim") + ", run `quickhook install` to install it"
+	case SHIM_OTHER:
+		return fmt.Sprintf("%v wasn't generated by Quickhook", hook.Shim)
+	case SHIM_OUTDATED:
+		description = color.YellowString("outdated") + " shim " + hook.Shim
+	default:
+		description = "shim " + hook.Shim
+	}
+	if hook.Symlink {
+		description = "symlink " + hook.Shim + " -> " + hook.Quickhook
+	} else {
+		description += fmt.Sprintf(" (runs %v", hook.Quickhook)
+		if hook.Version != "" {
+			description += fmt.Sprintf(", generated by %v", hook.Version)
+		}
+		description += fmt.Sprintf(", format %d)", hook.Format)
+	}
+	if hook.Legacy {
+		description += ", runs " + path.Base(hook.Shim) + LEGACY_SUFFIX + " first"
+	}
+	return description
+}
+
+func collectStatus(repo *repo.Repo) (*statusReport, error) {
+	hooksDir, err := repo.HooksDir()
+	if err != nil {
+		return nil, err
+	}
+	report := &statusReport{
+		HooksDir: repo.Rel(hooksDir),
+		Hooks:    []hookStatus{},
+	}
+
+	for _, hook := range hooks.GIT_HOOKS {
+		fullPath := path.Join(hooksDir, hook)
+		status := hookStatus{
+			Hook:           hook,
+			Shim:           repo.Rel(fullPath),
+			ShimStatus:     SHIM_MISSING,
+			Executables:    []string{},
+			NonExecutables: []string{},
+		}
+
+		stages := []string{hook}
+		if hook == "pre-commit" {
+			stages = append(stages, "pre-commit-mutating")
+		}
+		for _, stage := range stages {
+			executables, nonExecutables, err := repo.ListHookFiles(stage)
+			if err != nil {
+				return nil, err
+			}
+			status.Executables = append(status.Executables, executables...)
+			status.NonExecutables = append(status.NonExecutables, nonExecutables...)
+		}
+		sort.Strings(status.Executables)
+		sort.Strings(status.NonExecutables)
+
+		info, err := readShim(fullPath)
+		if err == nil {
+			status.ShimStatus = SHIM_OTHER
+			if info != nil {
+				status.ShimStatus = lo.Ternary(info.outdated(nil), SHIM_OUTDATED, SHIM_INSTALLED)
+				status.Version = info.version
+				status.Format = info.format
+				status.Quickhook = info.quickhook
+				status.Symlink = info.symlink
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if _, err := os.Lstat(fullPath + LEGACY_SUFFIX); err == nil {
+			status.Legacy = true
+		}
+
+		hasFiles := len(status.Executables) > 0 || len(status.NonExecutables) > 0
+		if status.ShimStatus == SHIM_MISSING && !hasFiles {
+			continue
+		}
+		report.Hooks = append(report.Hooks, status)
+	}
+	return report, nil
+}
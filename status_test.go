@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dirk/quickhook/internal/test"
+)
+
+func TestStatus(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "README"}, "Not a hook")
+	tempDir.MkdirAll(".quickhook", "commit-msg")
+	tempDir.WriteFile([]string{".quickhook", "commit-msg", "checks"}, "#!/bin/sh")
+	_, err := tempDir.ExecQuickhook("install", "--yes")
+	require.NoError(t, err)
+	tempDir.RequireExec("chmod", "-x", ".quickhook/pre-commit/README")
+	tempDir.RequireExec("rm", ".git/hooks/commit-msg")
+	tempDir.WriteFile([]string{".git", "hooks", "post-merge"}, "#!/bin/sh")
+
+	output, err := tempDir.ExecQuickhook("status")
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"Hooks directory: .git/hooks\n"+
+			"pre-commit: shim .git/hooks/pre-commit (runs quickhook, generated by main, format 4)\n"+
+			"  .quickhook/pre-commit/passes\n"+
+			"  .quickhook/pre-commit/README (not executable)\n"+
+			"commit-msg: no shim, run `quickhook install` to install it\n"+
+			"  .quickhook/commit-msg/checks\n"+
+			"post-merge: .git/hooks/post-merge wasn't generated by Quickhook\n",
+		output)
+}
+
+func TestStatusJSON(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.MkdirAll(".git", "hooks")
+	tempDir.WriteFile([]string{".git", "hooks", "pre-commit"}, "#!/bin/sh\nquickhook hook pre-commit\n")
+
+	output, err := tempDir.ExecQuickhook("status", "--json")
+	assert.NoError(t, err)
+	var report statusReport
+	require.NoError(t, json.Unmarshal([]byte(output), &report))
+	assert.Equal(t, statusReport{
+		HooksDir: ".git/hooks",
+		Hooks: []hookStatus{
+			{
+				Hook:           "pre-commit",
+				Shim:           ".git/hooks/pre-commit",
+				ShimStatus:     SHIM_OUTDATED,
+				Format:         1,
+				Quickhook:      "quickhook",
+				Executables:    []string{".quickhook/pre-commit/passes"},
+				NonExecutables: []string{},
+			},
+		},
+	}, report)
+}
+
+func TestStatusOutsideRepositoryExitsWithError(t *testing.T) {
+	tempDir := test.NewTempDir(t, 0)
+
+	output, err := tempDir.ExecQuickhook("--trace", "status")
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 1, exitErr.ExitCode())
+	assert.Contains(t, output, "quickhook: error: ")
+	assert.NotContains(t, output, "goroutine")
+	// The cleanups still ran.
+	assert.Contains(t, output, "Traced ")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dirk/quickhook/internal"
+	"github.com/dirk/quickhook/repo"
+)
+
+// Resolves the executable that symlinks should point to: the --bin command if given, otherwise
+// the one currently running.
+func symlinkTarget(bin string) (string, error) {
+	if bin == "" {
+		executable, err := os.Executable()
+		if err != nil {
+			return "", err
+		}
+		return filepath.EvalSymlinks(executable)
+	}
+	if len(strings.Fields(bin)) != 1 {
+		return "", fmt.Errorf("--bin must be a single executable when using --symlink: %v", bin)
+	}
+	executable, err := exec.LookPath(bin)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(executable)
+}
+
+// Symlink shims can't run a legacy hook, so if there is one (or there's an existing hook which
+// would become one) then a shim script has to be used instead.
+func canSymlink(fullPath string) (bool, error) {
+	if _, err := os.Lstat(fullPath + LEGACY_SUFFIX); err == nil {
+		return false, nil
+	}
+	isShim, err := isQuickhookShim(fullPath)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	return isShim, err
+}
+
+// Replaces the shim with a symlink to the Quickhook executable. Returns false without changing
+// anything if the hook needs a shim script (see canSymlink).
+func installSymlink(repo *repo.Repo, shimPath, target string) (bool, error) {
+	fullPath := repo.Abs(shimPath)
+	ok, err := canSymlink(fullPath)
+	if err != nil || !ok {
+		return false, err
+	}
+	err = os.MkdirAll(path.Dir(fullPath), 0755)
+	if err != nil {
+		return false, err
+	}
+
+	// Create it next to the shim and rename it into place, like installShim does.
+	tempPath := fmt.Sprintf("%v.%d.tmp", fullPath, os.Getpid())
+	err = os.Symlink(target, tempPath)
+	if err != nil {
+		return false, err
+	}
+	err = os.Rename(tempPath, fullPath)
+	if err != nil {
+		os.Remove(tempPath)
+		return false, err
+	}
+	return true, nil
+}
+
+// Returns true if the symlink points to the Quickhook executable that's running or to a shim
+// script. Executables can be large, so this never reads more than the start of the target.
+func isSymlinkShim(fullPath string) (bool, error) {
+	target, err := os.Stat(fullPath)
+	if err != nil {
+		// Dangling symlinks aren't ours to touch.
+		return false, nil
+	}
+	if executable, err := os.Executable(); err == nil {
+		if current, err := os.Stat(executable); err == nil && os.SameFile(current, target) {
+			return true, nil
+		}
+	}
+	resolved, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		return false, err
+	}
+	file, err := os.Open(resolved)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	header := make([]byte, internal.DIRECTIVES_HEADER_SIZE)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return strings.Contains(string(header[:n]), SHIM_MARKER), nil
+}
+
+// Converts the arguments Git passed to a hook into Quickhook's arguments for running it, for when
+// Quickhook is invoked through a symlink with the hook's name.
+func hookArgs(name string, args []string) []string {
+	switch name {
+	case "pre-commit", "prepare-commit-msg", "commit-msg", "post-commit", "post-checkout",
+		"post-merge", "pre-receive", "update", "post-receive", "pre-push":
+		// These take the same arguments as Git passes.
+		return append([]string{"hook", name}, args...)
+	default:
+		return append([]string{"hook", "run", name, "--"}, args...)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallSymlink(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "fails"}, "#!/bin/sh \n echo \"failed $(cat)\" \n exit 1")
+
+	output, err := tempDir.ExecQuickhook("install", "--yes", "--symlink")
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("Installed symlink .git/hooks/pre-commit -> %v\n", tempDir.Quickhook), output)
+	target, err := os.Readlink(path.Join(tempDir.Root, ".git", "hooks", "pre-commit"))
+	assert.NoError(t, err)
+	assert.Equal(t, tempDir.Quickhook, target)
+
+	tempDir.WriteFile([]string{"example.txt"}, "Changed!")
+	tempDir.RequireExec("git", "add", "example.txt")
+	commitOutput, err := tempDir.NewCommand("git", "commit", "--quiet", "--message", "Commit example.txt").CombinedOutput()
+	assert.Error(t, err)
+	assert.Equal(t, "fails: failed example.txt\n", string(commitOutput))
+
+	output, err = tempDir.ExecQuickhook("install", "--dry-run", "--symlink")
+	assert.NoError(t, err)
+	assert.Equal(t, "Unchanged .git/hooks/pre-commit\n", output)
+
+	output, err = tempDir.ExecQuickhook("uninstall", "--yes")
+	assert.NoError(t, err)
+	assert.Equal(t, "Removed shim .git/hooks/pre-commit\n", output)
+	assert.NoFileExists(t, path.Join(tempDir.Root, ".git", "hooks", "pre-commit"))
+}
+
+func TestInstallSymlinkWithLegacyHook(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.MkdirAll(".git", "hooks")
+	tempDir.WriteFile([]string{".git", "hooks", "pre-commit"}, "#!/bin/sh \n echo \"legacy passed\"")
+
+	output, err := tempDir.ExecQuickhook("install", "--yes", "--symlink")
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"Can't symlink .git/hooks/pre-commit since it needs to run the existing hook, using a shim script\n"+
+			"Moved existing hook to pre-commit.quickhook-legacy\n"+
+			"Installed shim .git/hooks/pre-commit\n",
+		output)
+	info, err := readShim(path.Join(tempDir.Root, ".git", "hooks", "pre-commit"))
+	require.NoError(t, err)
+	assert.Equal(t, &shimInfo{version: VERSION, format: SHIM_FORMAT, quickhook: tempDir.Quickhook}, info)
+}
+
+func TestUninstallKeepsSymlinksToOtherScripts(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.MkdirAll("tools")
+	tempDir.WriteFile([]string{"tools", "quickhook-lint.sh"}, "#!/bin/sh \n echo \"lint\"")
+	tempDir.MkdirAll(".git", "hooks")
+	link := path.Join(tempDir.Root, ".git", "hooks", "pre-commit")
+	require.NoError(t, os.Symlink("../../tools/quickhook-lint.sh", link))
+
+	output, err := tempDir.ExecQuickhook("uninstall", "--yes")
+	assert.NoError(t, err)
+	assert.Equal(t, "No shims found in .git/hooks\n", output)
+
+	output, err = tempDir.ExecQuickhook("install", "--yes")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "Moved existing hook to pre-commit.quickhook-legacy\n")
+	target, err := os.Readlink(link + LEGACY_SUFFIX)
+	require.NoError(t, err)
+	assert.Equal(t, "../../tools/quickhook-lint.sh", target)
+}
+
+func TestInstallSymlinkBinMustBeExecutable(t *testing.T) {
+	tempDir := initGitForInstall(t)
+
+	output, err := tempDir.ExecQuickhook("install", "--yes", "--symlink", "--bin=go tool quickhook")
+	assert.Error(t, err)
+	assert.Contains(t, output, "--bin must be a single executable when using --symlink")
+}
+
+func TestHookArgs(t *testing.T) {
+	assert.Equal(t, []string{"hook", "pre-commit"}, hookArgs("pre-commit", []string{}))
+	assert.Equal(t,
+		[]string{"hook", "post-checkout", "abc", "def", "1"},
+		hookArgs("post-checkout", []string{"abc", "def", "1"}))
+	assert.Equal(t,
+		[]string{"hook", "run", "pre-rebase", "--", "main"},
+		hookArgs("pre-rebase", []string{"main"}))
+}
+
+func TestIsSymlinkShim(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.MkdirAll(".git", "hooks")
+	tempDir.MkdirAll("bin")
+	// Not a copy of Quickhook, but named like one.
+	tempDir.WriteFile([]string{"bin", "quickhook-lint.sh"}, "#!/bin/sh")
+	tempDir.WriteFile([]string{"bin", "other-tool"}, "#!/bin/sh\n"+strings.Repeat("#\n", 4096)+SHIM_MARKER)
+	shim, err := shimContent("quickhook", "pre-commit")
+	require.NoError(t, err)
+	tempDir.WriteFile([]string{"bin", "shim"}, shim)
+
+	// The test binary is the executable that's running.
+	executable, err := os.Executable()
+	require.NoError(t, err)
+
+	hooksDir := path.Join(tempDir.Root, ".git", "hooks")
+	links := map[string]bool{
+		executable: true,
+		path.Join(tempDir.Root, "bin", "quickhook-lint.sh"): false,
+		path.Join(tempDir.Root, "bin", "shim"):              true,
+		// The marker is only looked for at the start.
+		path.Join(tempDir.Root, "bin", "other-tool"): false,
+		path.Join(tempDir.Root, "bin", "missing"):    false,
+	}
+	for target, expected := range links {
+		link := path.Join(hooksDir, "pre-commit")
+		os.Remove(link)
+		require.NoError(t, os.Symlink(target, link))
+		isShim, err := isSymlinkShim(link)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, isShim, target)
+	}
+}
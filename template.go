@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dirk/quickhook/repo"
+)
+
+// Hooks which get a shim in the global template. This is deliberately only the common
+// client-side hooks, since every shim costs starting a shell even in repositories which don't use
+// Quickhook.
+var TEMPLATE_HOOKS = []string{
+	"pre-commit",
+	"prepare-commit-msg",
+	"commit-msg",
+	"post-commit",
+	"post-checkout",
+	"post-merge",
+	"pre-push",
+}
+
+type templateOptions struct {
+	// Command to use for Quickhook in the shims.
+	quickhook string
+	prompt    bool
+	// Write the template here instead of to init.templateDir (or the default directory).
+	templateDir string
+}
+
+// Writes shims into a Git template directory and points init.templateDir at it, so that every
+// repository created by `git init` or `git clone` gets them. The shims only run Quickhook if the
+// repository has hooks for them in .quickhook.
+func installGlobalTemplate(options templateOptions) error {
+	templateDir, err := resolveTemplateDir(options.templateDir)
+	if err != nil {
+		return err
+	}
+
+	if options.prompt {
+		shouldInstall, err := promptYesNo(os.Stdin,
+			fmt.Sprintf("Install shims into Git template directory %v and set init.templateDir?", templateDir))
+		if err != nil {
+			return err
+		}
+		if !shouldInstall {
+			fmt.Println("Skipping installing global template")
+			return nil
+		}
+	}
+
+	for _, hook := range TEMPLATE_HOOKS {
+		fullPath := path.Join(templateDir, "hooks", hook)
+		if isShim, err := isQuickhookShim(fullPath); err == nil && !isShim {
+			// There's no legacy hook to chain to in the template, so don't clobber it.
+			fmt.Fprintf(os.Stderr, "Warning: Skipping %v since it wasn't generated by Quickhook\n", fullPath)
+			continue
+		} else if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		content, err := templateShimContent(options.quickhook, hook)
+		if err != nil {
+			return err
+		}
+		err = os.MkdirAll(path.Dir(fullPath), 0755)
+		if err != nil {
+			return err
+		}
+		err = writeShimFile(fullPath, content)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Installed shim %v\n", fullPath)
+	}
+
+	current, err := repo.GlobalConfigGetPath("init.templateDir")
+	if err != nil {
+		return err
+	}
+	if current != templateDir {
+		err = repo.GlobalConfigSet("init.templateDir", templateDir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Set init.templateDir to %v\n", templateDir)
+	}
+	return nil
+}
+
+// Uses the directory from the flag, then the one already in init.templateDir (so that anything
+// else in it keeps being used), and finally falls back to one in the user's config directory.
+func resolveTemplateDir(flag string) (string, error) {
+	if flag != "" {
+		return filepath.Abs(flag)
+	}
+	templateDir, err := repo.GlobalConfigGetPath("init.templateDir")
+	if err != nil {
+		return "", err
+	}
+	if templateDir != "" {
+		return filepath.Abs(templateDir)
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(configDir, "quickhook", "git-template"), nil
+}
+
+// Generates a shim for the template. Git runs hooks from the root of the working tree, so it can
+// check for the hook's directory before running Quickhook (or checking that it's installed).
+func templateShimContent(quickhook, hook string) (string, error) {
+	command, err := shimCommandForHook(quickhook, hook)
+	if err != nil {
+		return "", err
+	}
+
+	dirs := []string{"[ ! -d .quickhook/" + hook + " ]"}
+	if hook == "pre-commit" {
+		dirs = append(dirs, "[ ! -d .quickhook/pre-commit-mutating ]")
+	}
+
+	lines := shimHeader(quickhook, "quickhook install --global-template")
+	lines = append(lines, "if "+strings.Join(dirs, " && ")+"; then exit 0; fi")
+	lines = append(lines, shimMissingCheck(quickhook)...)
+	lines = append(lines, "exec "+command, "")
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dirk/quickhook/internal/test"
+)
+
+// Runs commands with a separate home directory so that they don't touch the real global config.
+func withHome(cmd *exec.Cmd, home string) *exec.Cmd {
+	cmd.Env = append(os.Environ(), "HOME="+home, "XDG_CONFIG_HOME="+path.Join(home, ".config"))
+	return cmd
+}
+
+func TestInstallGlobalTemplate(t *testing.T) {
+	tempDir := test.NewTempDir(t, 0)
+	home := t.TempDir()
+
+	output, err := withHome(tempDir.NewCommand(tempDir.Quickhook, "install", "--global-template", "--yes", "--bin="+tempDir.Quickhook), home).
+		CombinedOutput()
+	assert.NoError(t, err)
+	templateDir := path.Join(home, ".config", "quickhook", "git-template")
+	assert.Contains(t, string(output), "Installed shim "+path.Join(templateDir, "hooks", "pre-commit")+"\n")
+	assert.True(t, strings.HasSuffix(string(output), "Set init.templateDir to "+templateDir+"\n"))
+
+	config, err := withHome(tempDir.NewCommand("git", "config", "--global", "init.templateDir"), home).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, templateDir, strings.TrimSpace(string(config)))
+
+	// A new repository without any hooks isn't affected.
+	require.NoError(t, withHome(tempDir.NewCommand("git", "init", "--quiet", "."), home).Run())
+	tempDir.RequireExec("git", "config", "--local", "user.name", "example")
+	tempDir.RequireExec("git", "config", "--local", "user.email", "example@example.com")
+	assert.FileExists(t, path.Join(tempDir.Root, ".git", "hooks", "pre-commit"))
+	tempDir.WriteFile([]string{"example.txt"}, "Example")
+	tempDir.RequireExec("git", "add", "example.txt")
+	commitOutput, err := tempDir.NewCommand("git", "commit", "--quiet", "--message", "Add example.txt").CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(commitOutput))
+
+	// Once it has hooks they run without installing.
+	tempDir.MkdirAll(".quickhook", "pre-commit")
+	tempDir.WriteFile([]string{".quickhook", "pre-commit", "fails"}, "#!/bin/sh \n echo \"failed $(cat)\" \n exit 1")
+	tempDir.WriteFile([]string{"example.txt"}, "Changed!")
+	tempDir.RequireExec("git", "add", "example.txt")
+	commitOutput, err = tempDir.NewCommand("git", "commit", "--quiet", "--message", "Change example.txt").CombinedOutput()
+	assert.Error(t, err)
+	assert.Equal(t, "fails: failed example.txt\n", string(commitOutput))
+}
+
+func TestInstallGlobalTemplateUsesExistingTemplateDir(t *testing.T) {
+	tempDir := test.NewTempDir(t, 0)
+	home := t.TempDir()
+	require.NoError(t,
+		withHome(tempDir.NewCommand("git", "config", "--global", "init.templateDir", "~/template"), home).Run())
+	require.NoError(t, os.MkdirAll(path.Join(home, "template", "hooks"), 0755))
+	err := os.WriteFile(path.Join(home, "template", "hooks", "pre-push"), []byte("#!/bin/sh\necho custom\n"), 0755)
+	require.NoError(t, err)
+
+	output, err := withHome(tempDir.NewCommand(tempDir.Quickhook, "install", "--global-template", "--yes"), home).
+		CombinedOutput()
+	assert.NoError(t, err)
+	assert.Contains(t, string(output), "Installed shim "+path.Join(home, "template", "hooks", "pre-commit")+"\n")
+	assert.Contains(t, string(output), "Warning: Skipping "+path.Join(home, "template", "hooks", "pre-push"))
+	assert.NotContains(t, string(output), "Set init.templateDir")
+
+	data, err := os.ReadFile(path.Join(home, "template", "hooks", "pre-push"))
+	assert.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\necho custom\n", string(data))
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/dirk/quickhook/repo"
+)
+
+type uninstallOptions struct {
+	prompt bool
+	// Uninstall from this directory rather than the one Git uses.
+	hooksPath string
+}
+
+// Removes the shims generated by Quickhook and restores any hooks which were moved aside when
+// installing.
+func uninstall(repo *repo.Repo, options uninstallOptions) error {
+	hooksDir, err := repo.HooksDir()
+	if options.hooksPath != "" {
+		hooksDir, err = filepath.Abs(options.hooksPath)
+	}
+	if err != nil {
+		return err
+	}
+	shims, err := listShims(hooksDir)
+	if err != nil {
+		return err
+	}
+	if len(shims) == 0 {
+		fmt.Printf("No shims found in %v\n", repo.Rel(hooksDir))
+	}
+
+	for _, shim := range shims {
+		fullPath := path.Join(hooksDir, shim)
+		shimPath := repo.Rel(fullPath)
+		if options.prompt {
+			shouldRemove, err := promptYesNo(os.Stdin, fmt.Sprintf("Remove shim %v?", shimPath))
+			if err != nil {
+				return err
+			}
+			if !shouldRemove {
+				fmt.Printf("Skipping removing shim %v\n", shimPath)
+				continue
+			}
+		}
+
+		err = os.Remove(fullPath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed shim %v\n", shimPath)
+
+		legacyPath := fullPath + LEGACY_SUFFIX
+		if _, err := os.Lstat(legacyPath); err == nil {
+			err = os.Rename(legacyPath, fullPath)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Restored previous hook %v\n", shimPath)
+		}
+	}
+
+	// Undo `install --managed-hooks-path`.
+	quickhookDir, err := repo.QuickhookDir()
+	if err != nil {
+		return err
+	}
+	hooksPath, err := repo.ConfigGet("core.hooksPath")
+	if err != nil {
+		return err
+	}
+	if hooksPath == path.Join(quickhookDir, "hooks") {
+		previousHooksPath, err := repo.ConfigGet(PREVIOUS_HOOKS_PATH_KEY)
+		if err != nil {
+			return err
+		}
+		if options.prompt {
+			message := "Unset core.hooksPath?"
+			if previousHooksPath != "" {
+				message = fmt.Sprintf("Restore core.hooksPath to %v?", previousHooksPath)
+			}
+			shouldRestore, err := promptYesNo(os.Stdin, message)
+			if err != nil {
+				return err
+			}
+			if !shouldRestore {
+				fmt.Println("Skipping restoring core.hooksPath")
+				return nil
+			}
+		}
+		if previousHooksPath != "" {
+			err = repo.ConfigSet("core.hooksPath", previousHooksPath)
+			if err == nil {
+				err = repo.ConfigUnset(PREVIOUS_HOOKS_PATH_KEY)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Restored core.hooksPath to %v\n", previousHooksPath)
+		} else {
+			err = repo.ConfigUnset("core.hooksPath")
+			if err != nil {
+				return err
+			}
+			fmt.Println("Unset core.hooksPath")
+		}
+	}
+
+	return nil
+}
+
+// Returns the names of the files in the directory which are shims generated by Quickhook.
+func listShims(hooksDir string) ([]string, error) {
+	entries, err := os.ReadDir(hooksDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	shims := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		isShim, err := isQuickhookShim(path.Join(hooksDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if isShim {
+			shims = append(shims, entry.Name())
+		}
+	}
+	sort.Strings(shims)
+	return shims, nil
+}
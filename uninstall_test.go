@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUninstallRestoresPreviousHook(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.MkdirAll(".quickhook", "commit-msg")
+	tempDir.MkdirAll(".git", "hooks")
+	tempDir.WriteFile([]string{".git", "hooks", "pre-commit"}, "#!/bin/sh \n echo \"previous\"")
+	tempDir.WriteFile([]string{".git", "hooks", "other"}, "#!/bin/sh \n echo \"other\"")
+
+	output, err := tempDir.ExecQuickhook("install", "--yes")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Moved existing hook to pre-commit.quickhook-legacy")
+	assert.FileExists(t, path.Join(tempDir.Root, ".git", "hooks", "pre-commit.quickhook-legacy"))
+
+	output, err = tempDir.ExecQuickhook("uninstall", "--yes")
+	assert.NoError(t, err)
+	assert.Equal(t,
+		[]string{
+			"Removed shim .git/hooks/commit-msg",
+			"Removed shim .git/hooks/pre-commit",
+			"Restored previous hook .git/hooks/pre-commit",
+		},
+		strings.Split(strings.TrimSpace(output), "\n"))
+
+	assert.NoFileExists(t, path.Join(tempDir.Root, ".git", "hooks", "commit-msg"))
+	assert.NoFileExists(t, path.Join(tempDir.Root, ".git", "hooks", "pre-commit.quickhook-legacy"))
+	previous, err := os.ReadFile(path.Join(tempDir.Root, ".git", "hooks", "pre-commit"))
+	assert.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh \n echo \"previous\"", string(previous))
+	assert.FileExists(t, path.Join(tempDir.Root, ".git", "hooks", "other"))
+}
+
+func TestUninstallRecognizesOlderShims(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.MkdirAll(".git", "hooks")
+	tempDir.WriteFile([]string{".git", "hooks", "commit-msg"}, "#!/bin/sh\nquickhook hook commit-msg $1\n")
+
+	output, err := tempDir.ExecQuickhook("uninstall", "--yes")
+	assert.NoError(t, err)
+	assert.Equal(t, "Removed shim .git/hooks/commit-msg", strings.TrimSpace(output))
+}
+
+func TestUninstallManagedHooksPath(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--managed-hooks-path")
+	require.NoError(t, err)
+
+	output, err := tempDir.ExecQuickhook("uninstall", "--yes")
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"Removed shim .git/quickhook/hooks/pre-commit\nUnset core.hooksPath",
+		strings.TrimSpace(output))
+	err = tempDir.NewCommand("git", "config", "--get", "core.hooksPath").Run()
+	assert.Error(t, err)
+}
+
+func TestUninstallRestoresReplacedHooksPath(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.RequireExec("git", "config", "--local", "core.hooksPath", "shared-hooks")
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--managed-hooks-path", "--force")
+	require.NoError(t, err)
+
+	output, err := tempDir.ExecQuickhook("uninstall", "--yes")
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"Removed shim .git/quickhook/hooks/pre-commit\nRestored core.hooksPath to shared-hooks",
+		strings.TrimSpace(output))
+	hooksPath, err := tempDir.NewCommand("git", "config", "--get", "core.hooksPath").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "shared-hooks\n", string(hooksPath))
+	err = tempDir.NewCommand("git", "config", "--get", "quickhook.previousHooksPath").Run()
+	assert.Error(t, err)
+}
+
+func TestUninstallPromptsBeforeUnsettingHooksPath(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	_, err := tempDir.ExecQuickhook("install", "--yes", "--managed-hooks-path")
+	require.NoError(t, err)
+
+	cmd := tempDir.NewCommand(tempDir.Quickhook, "uninstall")
+	cmd.Stdin = strings.NewReader("n\nn\n")
+	output, err := cmd.CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"Remove shim .git/quickhook/hooks/pre-commit? [yn] Skipping removing shim .git/quickhook/hooks/pre-commit\n"+
+			"Unset core.hooksPath? [yn] Skipping restoring core.hooksPath",
+		strings.TrimSpace(string(output)))
+	err = tempDir.NewCommand("git", "config", "--get", "core.hooksPath").Run()
+	assert.NoError(t, err)
+}
+
+func TestUninstallPrompts(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	_, err := tempDir.ExecQuickhook("install", "--yes")
+	require.NoError(t, err)
+
+	cmd := tempDir.NewCommand(tempDir.Quickhook, "uninstall")
+	cmd.Stdin = strings.NewReader("n\n")
+	output, err := cmd.CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"Remove shim .git/hooks/pre-commit? [yn] Skipping removing shim .git/hooks/pre-commit",
+		strings.TrimSpace(string(output)))
+	assert.FileExists(t, path.Join(tempDir.Root, ".git", "hooks", "pre-commit"))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+
+	"github.com/dirk/quickhook/hooks"
+	"github.com/dirk/quickhook/internal"
+	"github.com/dirk/quickhook/repo"
+)
+
+type verifyResult struct {
+	commit    string
+	subject   string
+	preCommit error
+	commitMsg error
+}
+
+func (result *verifyResult) failed() bool {
+	return result.preCommit != nil || result.commitMsg != nil
+}
+
+// Replays the pre-commit and commit-msg hooks for every commit in the range. Each commit is
+// checked out in a temporary worktree so that the hooks see the files (and the hooks themselves)
+// as they were in that commit. Returns hooks.ErrFailed if any commit failed.
+func verify(repo *repo.Repo, fromRef, toRef string) error {
+	commits, err := repo.CommitsInRange(fromRef, toRef)
+	if err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		fmt.Printf("No commits in range %s..%s\n", fromRef, toRef)
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "quickhook-verify-*")
+	if err != nil {
+		return err
+	}
+	defer internal.AddCleanup(func() { os.RemoveAll(dir) })()
+	worktree, err := repo.AddWorktree(dir, commits[0])
+	if err != nil {
+		return err
+	}
+	defer internal.AddCleanup(func() { repo.RemoveWorktree(dir) })()
+
+	results := []verifyResult{}
+	for _, commit := range commits {
+		result, err := verifyCommit(worktree, commit)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	}
+
+	fmt.Println()
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "COMMIT\tPRE-COMMIT\tCOMMIT-MSG\tSUBJECT")
+	failed := false
+	for _, result := range results {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n",
+			shortCommit(result.commit),
+			verifyStatus(result.preCommit),
+			verifyStatus(result.commitMsg),
+			result.subject)
+		failed = result.failed() || failed
+	}
+	writer.Flush()
+
+	if failed {
+		return hooks.ErrFailed
+	}
+	return nil
+}
+
+func verifyCommit(worktree *repo.Repo, commit string) (verifyResult, error) {
+	result := verifyResult{commit: commit}
+
+	err := worktree.CheckoutDetached(commit)
+	if err != nil {
+		return result, err
+	}
+	result.subject, err = worktree.CommitFormat(commit, "%s")
+	if err != nil {
+		return result, err
+	}
+	fmt.Printf("%s %s\n", color.New(color.Bold).Sprint(shortCommit(commit)), result.subject)
+
+	preCommit := hooks.PreCommit{Repo: worktree, Commit: commit}
+	result.preCommit = preCommit.Run([]string{})
+	if result.preCommit != nil && !errors.Is(result.preCommit, hooks.ErrFailed) {
+		return result, result.preCommit
+	}
+
+	message, err := worktree.CommitFormat(commit, "%B")
+	if err != nil {
+		return result, err
+	}
+	messageFile, err := writeTempMessage(message)
+	if err != nil {
+		return result, err
+	}
+	defer internal.AddCleanup(func() { os.Remove(messageFile) })()
+	commitMsg := hooks.CommitMsg{Repo: worktree}
+	result.commitMsg = commitMsg.Run(messageFile)
+	if result.commitMsg != nil && !errors.Is(result.commitMsg, hooks.ErrFailed) {
+		return result, result.commitMsg
+	}
+
+	return result, nil
+}
+
+func writeTempMessage(message string) (string, error) {
+	file, err := os.CreateTemp("", "quickhook-verify-msg-*")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	_, err = file.WriteString(message + "\n")
+	if err != nil {
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+func verifyStatus(err error) string {
+	if err != nil {
+		return color.RedString("failed")
+	}
+	return color.GreenString("passed")
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 10 {
+		return commit[:10]
+	}
+	return commit
+}
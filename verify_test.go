@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyReportsFailingCommits(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.MkdirAll(".quickhook", "commit-msg")
+	tempDir.WriteFile(
+		[]string{".quickhook", "pre-commit", "no-bad-files"},
+		"#!/bin/sh \n if grep bad.txt; then exit 1; fi")
+	tempDir.WriteFile(
+		[]string{".quickhook", "commit-msg", "no-wip"},
+		"#!/bin/sh \n if grep WIP $1; then exit 1; fi")
+	tempDir.RequireExec("git", "add", ".quickhook")
+	tempDir.RequireExec("git", "commit", "--message", "Add more hooks", "--quiet", "--no-verify")
+	tempDir.WriteFile([]string{"good.txt"}, "Good")
+	tempDir.RequireExec("git", "add", "good.txt")
+	tempDir.RequireExec("git", "commit", "--message", "Add good file", "--quiet", "--no-verify")
+	tempDir.WriteFile([]string{"bad.txt"}, "Bad")
+	tempDir.RequireExec("git", "add", "bad.txt")
+	tempDir.RequireExec("git", "commit", "--message", "Add bad file", "--quiet", "--no-verify")
+	tempDir.RequireExec("git", "rm", "--quiet", "bad.txt")
+	tempDir.RequireExec("git", "commit", "--message", "WIP", "--quiet", "--no-verify")
+
+	output, err := tempDir.ExecQuickhook("verify", "--no-color", "HEAD~3..HEAD")
+	assert.Error(t, err)
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	require.GreaterOrEqual(t, len(lines), 4)
+	table := lines[len(lines)-4:]
+	assert.Equal(t, []string{"COMMIT", "PRE-COMMIT", "COMMIT-MSG", "SUBJECT"}, strings.Fields(table[0]))
+	assert.Equal(t, []string{"passed", "passed", "Add", "good", "file"}, strings.Fields(table[1])[1:])
+	assert.Equal(t, []string{"failed", "passed", "Add", "bad", "file"}, strings.Fields(table[2])[1:])
+	assert.Equal(t, []string{"passed", "failed", "WIP"}, strings.Fields(table[3])[1:])
+	assert.Contains(t, output, "no-bad-files: bad.txt")
+
+	// The temporary worktree should have been cleaned up.
+	tempDir.RequireExec("git", "worktree", "prune")
+	worktrees, err := tempDir.NewCommand("git", "worktree", "list").Output()
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(worktrees)), "\n"), 1)
+}
+
+func TestVerifyPassesWithPassingCommits(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.RequireExec("git", "commit", "--allow-empty", "--message", "Empty", "--quiet", "--no-verify")
+
+	output, err := tempDir.ExecQuickhook("verify", "--no-color", "HEAD~1..HEAD")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "passed      passed      Empty")
+}
+
+func TestVerifyWithHooksWhichChangeFiles(t *testing.T) {
+	tempDir := initGitForInstall(t)
+	tempDir.WriteFile(
+		[]string{".quickhook", "pre-commit", "formats"},
+		"#!/bin/sh \n for file in $(cat); do echo \"formatted\" >> \"$file\"; done \n touch untracked.txt")
+	tempDir.RequireExec("git", "add", ".quickhook")
+	tempDir.RequireExec("git", "commit", "--message", "Add more hooks", "--quiet", "--no-verify")
+	tempDir.WriteFile([]string{"example.txt"}, "First")
+	tempDir.RequireExec("git", "add", "example.txt")
+	tempDir.RequireExec("git", "commit", "--message", "First", "--quiet", "--no-verify")
+	tempDir.WriteFile([]string{"example.txt"}, "Second")
+	tempDir.RequireExec("git", "add", "example.txt")
+	tempDir.RequireExec("git", "commit", "--message", "Second", "--quiet", "--no-verify")
+
+	output, err := tempDir.ExecQuickhook("verify", "--no-color", "HEAD~2..HEAD")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "passed      passed      First")
+	assert.Contains(t, output, "passed      passed      Second")
+}